@@ -0,0 +1,310 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nav/config"
+	c "nav/constants"
+)
+
+var fmtDotNodeAnnotation = "\"%s\" [style=filled; fillcolor=\"%s\"; xlabel=\"%s\"];\n"
+
+// Tags the functions matching all the given regular expressions; an empty
+// one matches any function.
+type annotationRule struct {
+	Tag    string `json:"tag"`
+	Color  string `json:"color"`
+	Symbol string `json:"symbol"`
+	File   string `json:"file"`
+	Subsys string `json:"subsys"`
+	symbol *regexp.Regexp
+	file   *regexp.Regexp
+	subsys *regexp.Regexp
+}
+
+type annotations struct {
+	Rules []*annotationRule `json:"annotations"`
+}
+
+// Reads and validates an annotations file.
+func loadAnnotations(path string) (*annotations, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("problem while reading annotations file: %w", err)
+	}
+	a := &annotations{}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("problem while parsing annotations file: %w", err)
+	}
+	for i, r := range a.Rules {
+		if r.Tag == "" {
+			return nil, fmt.Errorf("annotation %d has no tag", i+1)
+		}
+		for _, p := range []struct {
+			expr string
+			re   **regexp.Regexp
+		}{{r.Symbol, &r.symbol}, {r.File, &r.file}, {r.Subsys, &r.subsys}} {
+			if *p.re, err = compileRulePattern(p.expr); err != nil {
+				return nil, fmt.Errorf("annotation %d (%s): %w", i+1, r.Tag, err)
+			}
+		}
+	}
+	return a, nil
+}
+
+// Returns the annotations of the configuration, nil if there are none.
+func newAnnotations(conf *config.ConfValues) (*annotations, error) {
+	if conf.Annotations == "" {
+		return nil, nil
+	}
+	return loadAnnotations(conf.Annotations)
+}
+
+func (r *annotationRule) match(symbol, file string, subsystems []string) bool {
+	if r.symbol != nil && !r.symbol.MatchString(symbol) {
+		return false
+	}
+	if r.file != nil && !r.file.MatchString(file) {
+		return false
+	}
+	if r.subsys == nil {
+		return true
+	}
+	for _, s := range subsystems {
+		if r.subsys.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the sorted tags of a function, and the color of the first rule
+// matching it which has one.
+func (a *annotations) tags(symbol, file string, subsystems []string) ([]string, string) {
+	var tags []string
+	var color string
+
+	if a == nil {
+		return nil, ""
+	}
+	for _, r := range a.Rules {
+		if !r.match(symbol, file, subsystems) {
+			continue
+		}
+		if !contains(tags, r.Tag) {
+			tags = append(tags, r.Tag)
+		}
+		if color == "" {
+			color = r.Color
+		}
+	}
+	sort.Strings(tags)
+	return tags, color
+}
+
+// Returns the dot statements coloring and labeling the annotated nodes of
+// the output graph, sorted by name. In the subsystem modes, a subsystem
+// gets the tags of its functions, and only the subsystems at the ends of
+// edges are in the graph.
+func dotAnnotations(info map[string]nodeInfo, a *annotations, mode c.OutMode, edges []*graphEdge) string {
+	var res string
+	var names []string
+
+	shown := map[string]bool{}
+	for _, e := range edges {
+		shown[e.l.subsys] = true
+		shown[e.r.subsys] = true
+	}
+	tags := map[string][]string{}
+	colors := map[string]string{}
+	// function whose color a subsystem takes, the first by name.
+	colorOf := map[string]string{}
+	for symbol, i := range info {
+		t, color := a.tags(symbol, i.file, i.subsystems)
+		if len(t) == 0 {
+			continue
+		}
+		name := symbol
+		if mode != c.PrintAll {
+			name = i.subsys
+			if !shown[name] {
+				continue
+			}
+		}
+		if _, ok := tags[name]; !ok {
+			names = append(names, name)
+		}
+		for _, tag := range t {
+			if !contains(tags[name], tag) {
+				tags[name] = append(tags[name], tag)
+			}
+		}
+		if color != "" && (colors[name] == "" || symbol < colorOf[name]) {
+			colors[name] = color
+			colorOf[name] = symbol
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		color := colors[name]
+		if color == "" {
+			color = "white"
+		}
+		sort.Strings(tags[name])
+		res += fmt.Sprintf(fmtDotNodeAnnotation, dotEscape(name), dotEscape(color), dotEscape(strings.Join(tags[name], ", ")))
+	}
+	return res
+}
+
+// Function reachable from the annotated ones without carrying their tag.
+type propagatedFunction struct {
+	Symbol string `json:"symbol"`
+	File   string `json:"file"`
+	From   string `json:"from"`
+	Depth  int    `json:"depth"`
+}
+
+type tagPropagation struct {
+	Tag         string                `json:"tag"`
+	Annotated   int                   `json:"annotated"`
+	Unannotated []*propagatedFunction `json:"unannotated"`
+}
+
+// Returns, for each tag, the functions reachable from the functions having
+// it which do not have it, with the nearest tagged function they are reached
+// from. Functions matching excluded are not walked through, and at most
+// maxDepth levels are walked, when not 0.
+func propagateAnnotations(d instanceSource, instance int, a *annotations, excluded []string, maxDepth int) ([]*tagPropagation, error) {
+	var res []*tagPropagation
+
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	g, err := loadCallGraph(d, instance)
+	if err != nil {
+		return nil, err
+	}
+	tagged := map[string]map[int]bool{}
+	for _, r := range a.Rules {
+		tagged[r.Tag] = map[int]bool{}
+	}
+	err = d.functions(instance, func(f function) error {
+		g.functions[f.id] = cycleFunction{f.symbol, f.file}
+		tags, _ := a.tags(f.symbol, f.file, subsystemsOf(subs, f.file))
+		for _, t := range tags {
+			tagged[t][f.id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for tag, roots := range tagged {
+		tp := &tagPropagation{Tag: tag, Annotated: len(roots), Unannotated: []*propagatedFunction{}}
+		var queue []int
+		from := map[int]int{}
+		depth := map[int]int{}
+		for id := range roots {
+			queue = append(queue, id)
+			from[id] = id
+		}
+		sort.Ints(queue)
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			if maxDepth > 0 && depth[id] >= maxDepth {
+				continue
+			}
+			if !roots[id] && !notExcluded(g.functions[id].Symbol, excluded) {
+				continue
+			}
+			for _, next := range g.succ[id] {
+				if _, ok := from[next]; ok {
+					continue
+				}
+				from[next] = from[id]
+				depth[next] = depth[id] + 1
+				queue = append(queue, next)
+				f := g.functions[next]
+				tp.Unannotated = append(tp.Unannotated, &propagatedFunction{f.Symbol, f.File, g.functions[from[id]].Symbol, depth[next]})
+			}
+		}
+		sort.Slice(tp.Unannotated, func(i, j int) bool {
+			a, b := tp.Unannotated[i], tp.Unannotated[j]
+			if a.Symbol != b.Symbol {
+				return a.Symbol < b.Symbol
+			}
+			return a.File < b.File
+		})
+		res = append(res, tp)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Tag < res[j].Tag })
+	return res, nil
+}
+
+func writePropagationText(w io.Writer, list []*tagPropagation) error {
+	for _, tp := range list {
+		if _, err := fmt.Fprintf(w, "%s: %d annotated, %d reachable not annotated\n", tp.Tag, tp.Annotated, len(tp.Unannotated)); err != nil {
+			return err
+		}
+		for _, f := range tp.Unannotated {
+			if _, err := fmt.Fprintf(w, "  %s (%s) from %s, depth %d\n", f.Symbol, f.File, f.From, f.Depth); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func writePropagationCSV(w io.Writer, list []*tagPropagation) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"tag", "symbol", "file", "from", "depth"}); err != nil {
+		return err
+	}
+	for _, tp := range list {
+		for _, f := range tp.Unannotated {
+			if err := cw.Write([]string{tp.Tag, f.Symbol, f.File, f.From, strconv.Itoa(f.Depth)}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeAnnotationsReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	a, err := newAnnotations(conf)
+	if err != nil {
+		return err
+	}
+	list, err := propagateAnnotations(d, conf.DBInstance, a, conf.ExcludedAfter, conf.MaxDepth)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(list)
+	case "csv":
+		return writePropagationCSV(w, list)
+	default:
+		return writePropagationText(w, list)
+	}
+}
@@ -0,0 +1,192 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+	c "nav/constants"
+)
+
+var _ = Describe("Annotations Tests", func() {
+	var dir, path string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "nav-annotations")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "annotations.json")
+		Expect(os.WriteFile(path, []byte(`{"annotations": [
+			{"tag": "safety", "color": "red", "symbol": "^a$"},
+			{"tag": "asil-b", "color": "orange", "subsys": "^SLAB$"},
+			{"tag": "safety", "file": "^mm/"}
+		]}`), 0644)).To(Succeed())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should tag the functions matching the rules", func() {
+		a, err := loadAnnotations(path)
+		Expect(err).To(BeNil())
+
+		tags, color := a.tags("b", "mm/b.c", []string{"MM", "SLAB"})
+		Expect(tags).To(Equal([]string{"asil-b", "safety"}))
+		Expect(color).To(Equal("orange"))
+
+		tags, color = a.tags("z", "kernel/z.c", nil)
+		Expect(tags).To(BeEmpty())
+		Expect(color).To(Equal(""))
+	})
+
+	It("Should reject invalid annotations", func() {
+		Expect(os.WriteFile(path, []byte(`{"annotations": [{"tag": "x", "symbol": "("}]}`), 0644)).To(Succeed())
+		_, err := loadAnnotations(path)
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(HavePrefix("annotation 1 (x): "))
+
+		Expect(os.WriteFile(path, []byte(`{"annotations": [{"color": "red"}]}`), 0644)).To(Succeed())
+		_, err = loadAnnotations(path)
+		Expect(err).To(MatchError("annotation 1 has no tag"))
+	})
+
+	It("Should label the nodes of the graph", func() {
+		a, err := loadAnnotations(path)
+		Expect(err).To(BeNil())
+		info := map[string]nodeInfo{
+			"a": {"SCHED", "kernel/a.c", []string{"SCHED"}, ""},
+			"b": {"MM", "mm/b.c", []string{"MM", "SLAB"}, ""},
+			"c": {"MM", "mm/c.c", []string{"MM"}, ""},
+			"d": {"SCHED", "kernel/d.c", []string{"SCHED"}, ""},
+		}
+
+		Expect(dotAnnotations(info, a, c.PrintAll, nil)).To(Equal(
+			"\"a\" [style=filled; fillcolor=\"red\"; xlabel=\"safety\"];\n" +
+				"\"b\" [style=filled; fillcolor=\"orange\"; xlabel=\"asil-b, safety\"];\n" +
+				"\"c\" [style=filled; fillcolor=\"white\"; xlabel=\"safety\"];\n"))
+		edges := []*graphEdge{{l: node{symbol: "a", subsys: "SCHED"}, r: node{symbol: "b", subsys: "MM"}}}
+		Expect(dotAnnotations(info, a, c.PrintSubsys, edges)).To(Equal(
+			"\"MM\" [style=filled; fillcolor=\"orange\"; xlabel=\"asil-b, safety\"];\n" +
+				"\"SCHED\" [style=filled; fillcolor=\"red\"; xlabel=\"safety\"];\n"))
+	})
+
+	It("Should only label the subsystems in the graph", func() {
+		a, err := loadAnnotations(path)
+		Expect(err).To(BeNil())
+		info := map[string]nodeInfo{
+			"a": {"SCHED", "kernel/a.c", []string{"SCHED"}, ""},
+			"b": {"MM", "mm/b.c", []string{"MM", "SLAB"}, ""},
+			"c": {"MM", "mm/c.c", []string{"MM"}, ""},
+		}
+		edges := []*graphEdge{{l: node{symbol: "c", subsys: "MM"}, r: node{symbol: "x", subsys: "VFS"}}}
+
+		Expect(dotAnnotations(info, a, c.PrintTargeted, edges)).To(Equal(
+			"\"MM\" [style=filled; fillcolor=\"orange\"; xlabel=\"asil-b, safety\"];\n"))
+	})
+
+	When("writeOutput", func() {
+		var d *sqlMock
+		var cfg config.Config
+
+		BeforeEach(func() {
+			d = &sqlMock{}
+			d.init(nil)
+			d.LOADsym2numValues("a", 1, 1, nil)
+			d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", subsys: []string{"SCHED"}, symId: 1}, nil)
+			d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+				{symbol: "b", fn: "mm/b.c", subsys: []string{"MM", "SLAB"}, symId: 2, sourceRef: "kernel/a.c:3", addressRef: "0x10"},
+			}, nil)
+			d.LOADgetSuccessorsByIdValues(2, 1, []entry{}, nil)
+			d.LOADgetSubsysFromSymbolNameValues("b", 1, "MM", nil)
+			cfg = config.Config{ConfValues: config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, Annotations: path}}
+		})
+
+		It("Should add the tags to the node list", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "csvNodes"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+			Expect(b.String()).To(Equal("symbol,file,subsystems,type,tags\n" +
+				"a,kernel/a.c,SCHED,entry,safety\n" +
+				"b,mm/b.c,MM;SLAB,function,asil-b;safety\n"))
+		})
+
+		It("Should add the tags of the caller and the callee to the edge list", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "csvEdges"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+			Expect(b.String()).To(Equal("caller,callee,caller_subsys,callee_subsys,source_line,ref_addr,depth,call_sites,caller_tags,callee_tags\n" +
+				"a,b,The REST,MM,kernel/a.c:3,0x10,1,1,safety,asil-b;safety\n"))
+		})
+
+		It("Should add the tags to the call tree", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "cflow"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+			Expect(b.String()).To(Equal("a() <kernel/a.c> {safety}:\n" +
+				"    b() <kernel/a.c:3> {asil-b, safety}\n"))
+		})
+
+		It("Should add annotation records to the json lines", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "jsonLines"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring(`{"type":"annotation","symbol":"a","tags":["safety"],"color":"red"}` + "\n" +
+				`{"type":"annotation","symbol":"b","tags":["asil-b","safety"],"color":"orange"}` + "\n" +
+				`{"type":"summary"`))
+		})
+
+		It("Should color the annotated functions of the graph", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "graphOnly"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+			Expect(b.String()).To(ContainSubstring("\"a\" [style=filled; fillcolor=\"red\"; xlabel=\"safety\"];\n"))
+		})
+	})
+
+	It("Should list the functions reachable from the annotated ones", func() {
+		src := &fakeInstance{
+			list: []call{
+				{1, 2, "a", "kernel/a.c", "helper", "lib/h.c", "kernel/a.c:3", "0x3"},
+				{2, 3, "helper", "lib/h.c", "b", "mm/b.c", "lib/h.c:5", "0x5"},
+				{3, 4, "b", "mm/b.c", "deep", "lib/deep.c", "mm/b.c:7", "0x7"},
+				{5, 4, "other", "kernel/o.c", "deep", "lib/deep.c", "kernel/o.c:1", "0x9"},
+			},
+			funcs: []function{{1, "a", "kernel/a.c", false}, {2, "helper", "lib/h.c", false}, {3, "b", "mm/b.c", false},
+				{4, "deep", "lib/deep.c", false}, {5, "other", "kernel/o.c", false}, {6, "lonely", "mm/l.c", false}},
+			subs: map[string][]string{"mm/b.c": {"SLAB"}},
+		}
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "annotations", ReportFormat: "text", Annotations: path}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("asil-b: 1 annotated, 1 reachable not annotated\n" +
+			"  deep (lib/deep.c) from b, depth 1\n" +
+			"safety: 3 annotated, 2 reachable not annotated\n" +
+			"  deep (lib/deep.c) from b, depth 1\n" +
+			"  helper (lib/h.c) from a, depth 1\n"))
+
+		buf.Reset()
+		conf.ExcludedAfter = []string{"^helper$"}
+		conf.ReportFormat = "csv"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("tag,symbol,file,from,depth\n" +
+			"asil-b,deep,lib/deep.c,b,1\n" +
+			"safety,deep,lib/deep.c,b,1\n" +
+			"safety,helper,lib/h.c,a,1\n"))
+	})
+})
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"nav/config"
+	"strings"
+)
+
+// Indentation of each level of the call tree, as cflow does.
+const cflowIndent = "    "
+
+// Datasource walking the call graph backwards: the successors of a function
+// are its callers.
+type callers struct {
+	Datasource
+}
+
+func (d callers) getSuccessorsById(symbolId int, instance int) ([]entry, error) {
+	return d.getPredecessorsById(symbolId, instance)
+}
+
+// Binds the queries of the wrapped datasource, if it can be bound, so that
+// the traversal can interrupt them.
+func (d callers) bindContext(ctx context.Context) {
+	if b, ok := d.Datasource.(contextBinder); ok {
+		b.bindContext(ctx)
+	}
+}
+
+// Writes the call tree of the graph in the format of cflow.
+// Each function is shown once with its callees; further occurrences refer to
+// the line where it was expanded, and recursive calls are marked.
+func writeCflow(w io.Writer, d Datasource, conf *config.ConfValues, start int, st *navState) error {
+	if conf.Reverse {
+		d = callers{d}
+	}
+	ann, err := newAnnotations(conf)
+	if err != nil {
+		return err
+	}
+	if err := explore(d, start, conf, st); err != nil {
+		return err
+	}
+
+	children := map[string][]*graphEdge{}
+	for _, e := range st.edges {
+		children[e.l.symbol] = append(children[e.l.symbol], e)
+	}
+
+	bw := bufio.NewWriter(w)
+	t := &cflowTree{bw, children, st.info, ann, map[string]int{}, map[string]bool{}, 0}
+	t.write(conf.Symbol, st.info[conf.Symbol].file, 0)
+	if st.truncated != "" {
+		fmt.Fprintf(bw, "(partial tree: %s)\n", st.truncated)
+	}
+	return bw.Flush()
+}
+
+// State of the call tree being written.
+type cflowTree struct {
+	w        *bufio.Writer
+	children map[string][]*graphEdge
+	info     map[string]nodeInfo
+	ann      *annotations
+	lines    map[string]int
+	path     map[string]bool
+	line     int
+}
+
+// Writes a function and, if it was not expanded yet, its callees. The tags
+// of annotated functions follow their location, in braces.
+func (t *cflowTree) write(symbol string, location string, depth int) {
+	t.line++
+	prefix := strings.Repeat(cflowIndent, depth) + symbol + "()"
+	if location != "" {
+		prefix += " <" + location + ">"
+	}
+	info := t.info[symbol]
+	if tags, _ := t.ann.tags(symbol, info.file, info.subsystems); len(tags) > 0 {
+		prefix += " {" + strings.Join(tags, ", ") + "}"
+	}
+	if t.path[symbol] {
+		fmt.Fprintf(t.w, "%s (recursive: see %d)\n", prefix, t.lines[symbol])
+		return
+	}
+	if n, ok := t.lines[symbol]; ok {
+		fmt.Fprintf(t.w, "%s [see %d]\n", prefix, n)
+		return
+	}
+	t.lines[symbol] = t.line
+	if len(t.children[symbol]) == 0 {
+		fmt.Fprintf(t.w, "%s\n", prefix)
+		return
+	}
+
+	fmt.Fprintf(t.w, "%s:\n", prefix)
+	t.path[symbol] = true
+	for _, e := range t.children[symbol] {
+		t.write(e.r.symbol, e.r.sourceRef, depth+1)
+	}
+	delete(t.path, symbol)
+}
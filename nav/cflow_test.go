@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"nav/config"
+	c "nav/constants"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cflow Tests", func() {
+	var d *sqlMock
+	var conf config.ConfValues
+
+	BeforeEach(func() {
+		// a -> b -> a, a -> c, b -> c
+		d = &sqlMock{}
+		d.init(nil)
+		d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", symId: 1}, nil)
+		d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+			{symbol: "b", symId: 2, sourceRef: "kernel/a.c:3"},
+			{symbol: "c", symId: 3, sourceRef: "kernel/a.c:4"},
+		}, nil)
+		d.LOADgetSuccessorsByIdValues(2, 1, []entry{
+			{symbol: "a", symId: 1, sourceRef: "kernel/b.c:7"},
+			{symbol: "c", symId: 3, sourceRef: "kernel/b.c:8"},
+		}, nil)
+		conf = config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, Type: "cflow"}
+	})
+
+	When("writeCflow", func() {
+		It("Should write the indented call tree", func() {
+			var b bytes.Buffer
+
+			Expect(writeCflow(&b, d, &conf, 1, newNavState())).To(Succeed())
+
+			Expect(b.String()).To(Equal("a() <kernel/a.c>:\n" +
+				"    b() <kernel/a.c:3>:\n" +
+				"        a() <kernel/b.c:7> (recursive: see 1)\n" +
+				"        c() <kernel/b.c:8>\n" +
+				"    c() <kernel/a.c:4> [see 4]\n"))
+		})
+
+		It("Should write the tree of the callers when reversed", func() {
+			var b bytes.Buffer
+			d.LOADgetEntryByIdValues(3, 1, entry{symbol: "c", fn: "kernel/c.c", symId: 3}, nil)
+			d.LOADgetPredecessorsByIdValues(3, 1, []entry{
+				{symbol: "a", symId: 1, sourceRef: "kernel/a.c:4"},
+				{symbol: "b", symId: 2, sourceRef: "kernel/b.c:8"},
+			}, nil)
+			conf.Symbol = "c"
+			conf.Reverse = true
+
+			Expect(writeCflow(&b, d, &conf, 3, newNavState())).To(Succeed())
+
+			Expect(b.String()).To(Equal("c() <kernel/c.c>:\n" +
+				"    a() <kernel/a.c:4>\n" +
+				"    b() <kernel/b.c:8>\n"))
+		})
+	})
+})
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Criteria to group functions in clusters.
+const (
+	ClusterBySubsys = "subsys"
+	ClusterByDir    = "dir"
+)
+
+// Background colors assigned to clusters, in order.
+var clusterColors = []string{
+	"lightblue", "lightpink", "palegreen", "khaki", "plum", "lightsalmon",
+	"paleturquoise", "wheat", "thistle", "lightcyan", "mistyrose", "honeydew",
+}
+
+var fmtDotCluster = "subgraph \"cluster_%d\" {\nlabel=\"%s\"; style=filled; fillcolor=%s;\n%s}\n"
+var fmtDotLegend = "subgraph \"cluster_legend\" {\nlabel=\"Legend\"; style=solid;\n%s}\n"
+var fmtDotLegendEntry = "\"__legend_%d\" [shape=box; style=filled; fillcolor=%s; label=\"%s\"];\n"
+
+// Returns the cluster a function belongs to.
+func clusterOf(info nodeInfo, by string) string {
+	switch by {
+	case ClusterBySubsys:
+		return info.subsys
+	case ClusterByDir:
+		if info.file == "" {
+			return SUBSYS_UNDEF
+		}
+		return filepath.Dir(info.file)
+	}
+	return ""
+}
+
+// Returns the dot subgraphs grouping the functions of the output graph by
+// subsystem or by source directory, followed by a legend.
+// Clusters and their members are sorted by name.
+func dotClusters(info map[string]nodeInfo, by string) string {
+	var res, legend string
+	var names []string
+
+	members := map[string][]string{}
+	for symbol, i := range info {
+		name := clusterOf(i, by)
+		if _, ok := members[name]; !ok {
+			names = append(names, name)
+		}
+		members[name] = append(members[name], symbol)
+	}
+	sort.Strings(names)
+
+	for n, name := range names {
+		var body strings.Builder
+		color := clusterColors[n%len(clusterColors)]
+		sort.Strings(members[name])
+		for _, symbol := range members[name] {
+			fmt.Fprintf(&body, "\"%s\";\n", symbol)
+		}
+		res += fmt.Sprintf(fmtDotCluster, n, name, color, body.String())
+		legend += fmt.Sprintf(fmtDotLegendEntry, n, color, name)
+	}
+	if legend != "" {
+		res += fmt.Sprintf(fmtDotLegend, legend)
+	}
+	return res
+}
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"nav/config"
+	c "nav/constants"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cluster Tests", func() {
+	info := map[string]nodeInfo{
+		"b": {subsys: "mm", file: "mm/slab.c"},
+		"a": {subsys: "sched", file: "kernel/sched/core.c"},
+		"c": {subsys: "mm", file: "mm/vmalloc.c"},
+	}
+
+	When("dotClusters", func() {
+		It("Should group the functions by subsystem", func() {
+			res := dotClusters(info, ClusterBySubsys)
+
+			Expect(res).To(Equal(
+				"subgraph \"cluster_0\" {\nlabel=\"mm\"; style=filled; fillcolor=lightblue;\n\"b\";\n\"c\";\n}\n" +
+					"subgraph \"cluster_1\" {\nlabel=\"sched\"; style=filled; fillcolor=lightpink;\n\"a\";\n}\n" +
+					"subgraph \"cluster_legend\" {\nlabel=\"Legend\"; style=solid;\n" +
+					"\"__legend_0\" [shape=box; style=filled; fillcolor=lightblue; label=\"mm\"];\n" +
+					"\"__legend_1\" [shape=box; style=filled; fillcolor=lightpink; label=\"sched\"];\n}\n"))
+		})
+
+		It("Should group the functions by source directory", func() {
+			res := dotClusters(info, ClusterByDir)
+
+			Expect(res).To(ContainSubstring("label=\"kernel/sched\"; style=filled; fillcolor=lightblue;\n\"a\";\n}"))
+			Expect(res).To(ContainSubstring("label=\"mm\"; style=filled; fillcolor=lightpink;\n\"b\";\n\"c\";\n}"))
+		})
+
+		It("Should return nothing for an empty graph", func() {
+			Expect(dotClusters(map[string]nodeInfo{}, ClusterBySubsys)).To(BeEmpty())
+		})
+	})
+
+	When("writeDot", func() {
+		It("Should place every function of the graph in a cluster", func() {
+			var b bytes.Buffer
+			d := &sqlMock{}
+			d.init(nil)
+			d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", symId: 1}, nil)
+			d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+				{symbol: "b", fn: "mm/b.c", symId: 2, addressRef: "0x10"},
+			}, nil)
+			conf := config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, ClusterBy: ClusterByDir}
+
+			Expect(writeDot(&b, d, &conf, 1, newNavState())).To(BeNil())
+
+			Expect(b.String()).To(ContainSubstring("label=\"kernel\"; style=filled; fillcolor=lightblue;\n\"a\";\n}"))
+			Expect(b.String()).To(ContainSubstring("label=\"mm\"; style=filled; fillcolor=lightpink;\n\"b\";\n}"))
+		})
+	})
+})
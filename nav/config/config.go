@@ -6,11 +6,16 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/spf13/pflag"
 	c "nav/constants"
 )
 
+// Graphviz size attribute: width,height in inches, optionally forced by '!'.
+var sizeRe = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?(,[0-9]+(\.[0-9]+)?)?!?$`)
+
 type Config struct {
 	ConfValues ConfValues
 	// TODO: add DB connection instance here
@@ -28,6 +33,38 @@ type ConfValues struct {
 	Mode           c.OutMode  `json:"mode"`
 	Graphviz       c.OutIMode `json:"out_type"`
 	DBInstance     int        `json:"db_instance"`
+	EdgeWeights    bool       `json:"edge_weights"`
+	MaxNodes       int        `json:"max_nodes"`
+	MaxEdges       int        `json:"max_edges"`
+	Timeout        int        `json:"timeout"`
+	Workers        int        `json:"workers"`
+	CacheDir       string     `json:"cache_dir"`
+	Layout         string     `json:"layout"`
+	Size           string     `json:"size"`
+	DPI            float64    `json:"dpi"`
+	ClusterBy      string     `json:"cluster_by"`
+	URLTemplate    string     `json:"url_template"`
+	Tooltips       bool       `json:"tooltips"`
+	SourceTree     string     `json:"source_tree"`
+	SourcePrefix   string     `json:"source_prefix"`
+	SnippetLines   int        `json:"snippet_lines"`
+	HTMLReport     string     `json:"html_report"`
+	Reverse        bool       `json:"reverse"`
+	Instance       string     `json:"instance"`
+	ListInstances  bool       `json:"-"`
+	Profile        string     `json:"profile"`
+	ProfilesFile   string     `json:"profiles_file"`
+	Report         string     `json:"report"`
+	ReportFormat   string     `json:"report_format"`
+	RulesFile      string     `json:"rules_file"`
+	EntryPoints    []string   `json:"entry_points"`
+	Arch           string     `json:"arch"`
+	SyscallRegexps []string   `json:"syscall_patterns"`
+	Patch          string     `json:"patch"`
+	Annotations    string     `json:"annotations"`
+	FeatureName    string     `json:"feature_name"`
+	FeatureSymbols []string   `json:"feature_symbols"`
+	Requirements   string     `json:"requirements"`
 }
 
 // New creates a new Config instance and returns a pointer to it.
@@ -41,10 +78,11 @@ func New() (*Config, error) {
 	return &config, nil
 }
 
-// initConfig gathers the configuration values from command line and config file (if provided) and returns
-// them in a ConfValues struct. If both are provided, the command line flags take precedence.
+// initConfig gathers the configuration values from config file (if provided), database profile, environment
+// variables and command line, and returns them in a ConfValues struct. Each source takes precedence over the
+// previous ones.
 func initConfig() (ConfValues, error) {
-	if len(os.Args) == 1 {
+	if len(os.Args) == 1 && !envConfigured() {
 		return ConfValues{}, fmt.Errorf("error: no configuration was specified, please specify a configuration file or use the command line flags; " +
 			"use -h or --help for more information")
 	}
@@ -64,6 +102,18 @@ func initConfig() (ConfValues, error) {
 		}
 	}
 
+	profile := lookupSetting(fs, "profile", confValues.Profile)
+	if profile != "" {
+		p, err := loadProfile(lookupSetting(fs, "profiles_file", confValues.ProfilesFile), profile)
+		if err != nil {
+			return ConfValues{}, fmt.Errorf("error: %w", err)
+		}
+		p.apply(&confValues)
+	}
+
+	if err := setEnv(&confValues); err != nil {
+		return ConfValues{}, fmt.Errorf("error: %w", err)
+	}
 	setFlags(fs, &confValues)
 	if err := confValues.validate(); err != nil {
 		return ConfValues{}, fmt.Errorf("invalid configuration: %w", err)
@@ -72,6 +122,20 @@ func initConfig() (ConfValues, error) {
 	return confValues, nil
 }
 
+// Returns the value of a setting needed before the other sources are applied,
+// taking into account the command line, the environment and the config file
+// value, in order of precedence.
+func lookupSetting(fs *pflag.FlagSet, jsonName string, fileValue string) string {
+	flag := strings.ReplaceAll(jsonName, "_", "-")
+	if f := fs.Lookup(flag); f != nil && f.Changed {
+		return f.Value.String()
+	}
+	if v, ok := os.LookupEnv(envName(jsonName)); ok {
+		return v
+	}
+	return fileValue
+}
+
 func loadConfigFile(cfg *ConfValues, configPath string) error {
 	cleanedPath := filepath.Clean(configPath)
 
@@ -99,14 +163,31 @@ func loadConfigFile(cfg *ConfValues, configPath string) error {
 }
 
 func (cfg *ConfValues) validate() error {
-	if cfg.Symbol == "" {
+	if cfg.Symbol == "" && !cfg.ListInstances && cfg.Report == "" {
 		return fmt.Errorf("symbol must be specified")
 	}
 	if cfg.MaxDepth < 0 {
 		return fmt.Errorf("invalid depth: %d", cfg.MaxDepth)
 	}
-	if err := validateDBInstance(&cfg.DBInstance); err != nil {
-		return err
+	if cfg.MaxNodes < 0 {
+		return fmt.Errorf("invalid max nodes: %d", cfg.MaxNodes)
+	}
+	if cfg.MaxEdges < 0 {
+		return fmt.Errorf("invalid max edges: %d", cfg.MaxEdges)
+	}
+	if cfg.Timeout < 0 {
+		return fmt.Errorf("invalid timeout: %d", cfg.Timeout)
+	}
+	if cfg.Workers < 0 {
+		return fmt.Errorf("invalid workers: %d", cfg.Workers)
+	}
+	if cfg.SnippetLines < 0 {
+		return fmt.Errorf("invalid snippet lines: %d", cfg.SnippetLines)
+	}
+	if cfg.Instance == "" {
+		if err := validateDBInstance(&cfg.DBInstance); err != nil {
+			return err
+		}
 	}
 	if err := validateDBDriver(&cfg.DBDriver); err != nil {
 		return err
@@ -120,6 +201,63 @@ func (cfg *ConfValues) validate() error {
 	if err := validateGType(&cfg.Graphviz); err != nil {
 		return err
 	}
+	if err := validateLayout(cfg.Layout); err != nil {
+		return err
+	}
+	if (cfg.Size != "") && !sizeRe.MatchString(cfg.Size) {
+		return fmt.Errorf("invalid size: %s\nUse the graphviz format, i.e. \"7.5,10\" or \"7.5,10!\"", cfg.Size)
+	}
+	if cfg.DPI < 0 {
+		return fmt.Errorf("invalid dpi: %g", cfg.DPI)
+	}
+	if err := validateClusterBy(cfg.ClusterBy, cfg.Mode); err != nil {
+		return err
+	}
+	if (cfg.URLTemplate != "" || cfg.Tooltips) && cfg.Mode != c.PrintAll {
+		return fmt.Errorf("links and tooltips are available only in mode %d", c.PrintAll)
+	}
+	if cfg.Type == "cflow" && cfg.Mode != c.PrintAll {
+		return fmt.Errorf("cflow output is available only in mode %d", c.PrintAll)
+	}
+	if cfg.Reverse && cfg.Type != "cflow" {
+		return fmt.Errorf("reverse call trees are available only with the cflow output type")
+	}
+	if cfg.HTMLReport != "" && cfg.Mode > c.PrintTargeted {
+		return fmt.Errorf("html report is not available in mode %d", cfg.Mode)
+	}
+	if err := validateReport(cfg.Report, &cfg.ReportFormat); err != nil {
+		return err
+	}
+	if (cfg.Report == "layering") != (cfg.RulesFile != "") {
+		return fmt.Errorf("the layering report requires a rules file, and rules files are used only by it")
+	}
+	if (cfg.Report == "impact") != (cfg.Patch != "") {
+		return fmt.Errorf("the impact report requires a patch, and patches are used only by it")
+	}
+	if cfg.Report == "annotations" && cfg.Annotations == "" {
+		return fmt.Errorf("the annotations report requires an annotations file")
+	}
+	if cfg.Annotations != "" && cfg.Report != "" && cfg.Report != "annotations" && cfg.Report != "traceability" {
+		return fmt.Errorf("annotations are used only by the annotations and traceability reports, not by the %s report", cfg.Report)
+	}
+	if cfg.Annotations != "" && cfg.Report == "" && cfg.Mode > c.PrintTargeted {
+		return fmt.Errorf("annotations are not available in mode %d", cfg.Mode)
+	}
+	if cfg.Report == "traceability" && cfg.Symbol == "" && len(cfg.FeatureSymbols) == 0 {
+		return fmt.Errorf("the traceability report requires a symbol or the feature symbols")
+	}
+	if (cfg.Report == "requirements") != (cfg.Requirements != "") {
+		return fmt.Errorf("the requirements report requires a requirements file, and requirements files are used only by it")
+	}
+	if cfg.Report == "requirements" && cfg.Symbol == "" && len(cfg.FeatureSymbols) == 0 {
+		return fmt.Errorf("the requirements report requires a symbol or the feature symbols")
+	}
+	if cfg.Report == "syscalls" && cfg.Symbol == "" {
+		return fmt.Errorf("the syscalls report requires a symbol")
+	}
+	if err := validateArch(cfg.Arch); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -131,7 +269,7 @@ func validateDBInstance(i *int) error {
 	switch *i {
 	case 0:
 		*i = c.DefaultDBInstance
-		fmt.Printf("No database instance specified. Defaulting to %d.\n", c.DefaultDBInstance)
+		fmt.Fprintf(os.Stderr, "No database instance specified. Defaulting to %d.\n", c.DefaultDBInstance)
 		return nil
 	default:
 		return nil
@@ -142,7 +280,7 @@ func validateDBDriver(d *string) error {
 	switch *d {
 	case "":
 		*d = c.DefaultDBDriver
-		fmt.Printf("No database driver specified. Defaulting to %s.\n", c.DefaultDBDriver)
+		fmt.Fprintf(os.Stderr, "No database driver specified. Defaulting to %s.\n", c.DefaultDBDriver)
 		return nil
 	case "mysql", "postgres", "sqlite3":
 		return nil
@@ -155,7 +293,7 @@ func validateMode(m *c.OutMode) error {
 	switch *m {
 	case 0:
 		*m = c.DefaultMode
-		fmt.Printf("No output mode specified. Defaulting to %d.\n", c.DefaultMode)
+		fmt.Fprintf(os.Stderr, "No output mode specified. Defaulting to %d.\n", c.DefaultMode)
 		return nil
 	case c.PrintAll, c.PrintSubsys, c.PrintSubsysWs, c.PrintTargeted, c.GDataFunc, c.GDataSubs:
 		return nil
@@ -169,12 +307,14 @@ func validateType(t *string) error {
 	switch *t {
 	case "":
 		*t = c.DefaultOutputType
-		fmt.Printf("No output type specified. Defaulting to %s.\n", c.DefaultOutputType)
+		fmt.Fprintf(os.Stderr, "No output type specified. Defaulting to %s.\n", c.DefaultOutputType)
 		return nil
-	case "graphOnly", "jsonOutputPlain", "jsonOutputB64", "jsonOutputGZB64":
+	case "graphOnly", "jsonOutputPlain", "jsonOutputB64", "jsonOutputGZB64", "jsonLines", "cflow",
+		"csvEdges", "tsvEdges", "csvNodes", "tsvNodes":
 		return nil
 	default:
-		return fmt.Errorf("invalid output type: %s\nChoose one of the following: graphOnly, jsonOutputPlain, jsonOutputB64 or jsonOutputGZB64", *t)
+		return fmt.Errorf("invalid output type: %s\nChoose one of the following: graphOnly, jsonOutputPlain, jsonOutputB64, jsonOutputGZB64, jsonLines, cflow, "+
+			"csvEdges, tsvEdges, csvNodes or tsvNodes", *t)
 	}
 }
 
@@ -182,11 +322,80 @@ func validateGType(t *c.OutIMode) error {
         switch *t {
         case 0:
                 *t = c.DefaultGOutputType
-                fmt.Printf("No output format specified. Defaulting to %d.\n", c.DefaultGOutputType)
+                fmt.Fprintf(os.Stderr, "No output format specified. Defaulting to %d.\n", c.DefaultGOutputType)
                 return nil
-        case c.OText, c.OPNG, c.OJPG, c.OSVG:
+        case c.OText, c.OPNG, c.OJPG, c.OSVG, c.OPDF:
                 return nil
         default:
-                return fmt.Errorf("invalid graphviz output type: %s\nSee help for more details.", *t)
+                return fmt.Errorf("invalid graphviz output type: %d\nSee help for more details.", *t)
         }
 }
+
+func validateLayout(l string) error {
+	switch l {
+	case "", "dot", "fdp", "sfdp", "neato":
+		return nil
+	default:
+		return fmt.Errorf("invalid layout engine: %s\nChoose one of the following: dot, fdp, sfdp or neato", l)
+	}
+}
+
+// Output formats of each report, the first is the default.
+var reportFormats = map[string][]string{
+	"matrix":       {"csv", "json", "dot"},
+	"surface":      {"text", "json", "csv"},
+	"layering":     {"text", "json", "junit"},
+	"cycles":       {"text", "json"},
+	"unreferenced": {"text", "json", "csv"},
+	"syscalls":     {"text", "json", "dot"},
+	"impact":       {"text", "json", "dot"},
+	"annotations":  {"text", "json", "csv"},
+	"traceability": {"markdown", "html", "csv"},
+	"requirements": {"text", "json", "csv"},
+}
+
+func validateReport(report string, format *string) error {
+	if report == "" {
+		if *format != "" {
+			return fmt.Errorf("report format requires a report")
+		}
+		return nil
+	}
+	formats, ok := reportFormats[report]
+	if !ok {
+		return fmt.Errorf("invalid report: %s\nChoose one of the following: matrix, surface, layering, cycles, unreferenced, syscalls, impact, annotations, traceability or requirements", report)
+	}
+	if *format == "" {
+		*format = formats[0]
+		return nil
+	}
+	for _, f := range formats {
+		if f == *format {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid format for the %s report: %s\nChoose one of the following: %s", report, *format, strings.Join(formats, ", "))
+}
+
+func validateArch(arch string) error {
+	switch arch {
+	case "", "x86_64", "arm64", "riscv", "s390x", "powerpc", "generic":
+		return nil
+	default:
+		return fmt.Errorf("invalid arch: %s\nChoose one of the following: x86_64, arm64, riscv, s390x, powerpc or generic", arch)
+	}
+}
+
+func validateClusterBy(by string, m c.OutMode) error {
+	switch by {
+	case "":
+		return nil
+	case "subsys", "dir":
+		if m != c.PrintAll {
+			return fmt.Errorf("clustering is available only in mode %d", c.PrintAll)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid cluster criterion: %s\nChoose one of the following: subsys or dir", by)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -49,10 +50,10 @@ var _ = Describe("Config Tests", func() {
 
 		When("The CLI is invoked with an invalid mode value", func() {
 			It("Should fail and inform the user about the invalid mode", func() {
-				os.Args = []string{"nav", "-s", "symbol", "--mode", "5"}
+				os.Args = []string{"nav", "-s", "symbol", "--mode", "99"}
 				_, err := initConfig()
 				Expect(err).ToNot(BeNil())
-				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: invalid output mode: 5\nChoose one of the following: 1=Symbols, 2=Subsystems, 3=Subsystems with labels, 4=Target subsystem isolation"))
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: invalid output mode: 99\nChoose one of the following: 1=Symbols, 2=Subsystems, 3=Subsystems with labels, 4=Target subsystem isolation"))
 			})
 		})
 
@@ -65,6 +66,121 @@ var _ = Describe("Config Tests", func() {
 			})
 		})
 
+		When("The CLI is invoked with an invalid node budget", func() {
+			It("Should fail and inform the user about the invalid node budget", func() {
+				os.Args = []string{"nav", "-s", "symbol", "--max-nodes", "-1"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: invalid max nodes: -1"))
+			})
+		})
+
+		When("The CLI is invoked with an invalid layout engine", func() {
+			It("Should fail and inform the user about the invalid layout engine", func() {
+				os.Args = []string{"nav", "-s", "symbol", "--layout", "circus"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: invalid layout engine: circus\nChoose one of the following: dot, fdp, sfdp or neato"))
+			})
+		})
+
+		When("The CLI is invoked with an invalid size", func() {
+			It("Should fail and inform the user about the invalid size", func() {
+				os.Args = []string{"nav", "-s", "symbol", "--size", "big"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(HavePrefix("invalid configuration: invalid size: big"))
+			})
+		})
+
+		When("The CLI is invoked with clustering outside mode 1", func() {
+			It("Should fail and inform the user that clustering needs mode 1", func() {
+				os.Args = []string{"nav", "-s", "symbol", "-m", "2", "--cluster-by", "subsys"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: clustering is available only in mode 1"))
+			})
+		})
+
+		When("The CLI is invoked with annotations in a global data mode", func() {
+			It("Should fail and inform the user that annotations are not shown", func() {
+				os.Args = []string{"nav", "-s", "symbol", "-m", "5", "--annotations", "annotations.json"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: annotations are not available in mode 5"))
+			})
+		})
+
+		When("The CLI is invoked with tooltips outside mode 1", func() {
+			It("Should fail and inform the user that tooltips need mode 1", func() {
+				os.Args = []string{"nav", "-s", "symbol", "-m", "2", "--tooltips"}
+				_, err := initConfig()
+				Expect(err).ToNot(BeNil())
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: links and tooltips are available only in mode 1"))
+			})
+		})
+
+		When("The CLI is invoked to list the instances", func() {
+			It("Should not require a symbol", func() {
+				os.Args = []string{"nav", "--list-instances"}
+				conf, err := initConfig()
+				Expect(err).To(BeNil())
+				Expect(conf.ListInstances).To(BeTrue())
+			})
+		})
+
+		When("The CLI is invoked to produce a report", func() {
+			It("Should not require a symbol and default the format", func() {
+				os.Args = []string{"nav", "--report", "matrix"}
+				conf, err := initConfig()
+				Expect(err).To(BeNil())
+				Expect(conf.ReportFormat).To(Equal("csv"))
+			})
+			It("Should keep the standard output for the report", func() {
+				os.Args = []string{"nav", "--report", "matrix", "--report-format", "json"}
+				r, w, err := os.Pipe()
+				Expect(err).To(BeNil())
+				stdout := os.Stdout
+				os.Stdout = w
+				_, err = initConfig()
+				os.Stdout = stdout
+				w.Close()
+				out, _ := io.ReadAll(r)
+				Expect(err).To(BeNil())
+				Expect(string(out)).To(BeEmpty())
+			})
+			It("Should reject a format the report does not support", func() {
+				os.Args = []string{"nav", "--report", "matrix", "--report-format", "junit"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: invalid format for the matrix report: junit\nChoose one of the following: csv, json, dot"))
+			})
+			It("Should require the symbol of the syscalls report", func() {
+				os.Args = []string{"nav", "--report", "syscalls", "--arch", "arm64"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: the syscalls report requires a symbol"))
+			})
+			It("Should reject an unknown arch", func() {
+				os.Args = []string{"nav", "--report", "syscalls", "-s", "vfs_read", "--arch", "vax"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(HavePrefix("invalid configuration: invalid arch: vax"))
+			})
+			It("Should require the rules of the layering report", func() {
+				os.Args = []string{"nav", "--report", "layering"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: the layering report requires a rules file, and rules files are used only by it"))
+			})
+			It("Should require the requirements file of the requirements report", func() {
+				os.Args = []string{"nav", "--report", "requirements", "-s", "vfs_read"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: the requirements report requires a requirements file, and requirements files are used only by it"))
+			})
+			It("Should reject annotations the report does not use", func() {
+				os.Args = []string{"nav", "--report", "matrix", "--annotations", "annotations.json"}
+				_, err := initConfig()
+				Expect(fmt.Sprintf("%s", err)).To(Equal("invalid configuration: annotations are used only by the annotations and traceability reports, not by the matrix report"))
+			})
+		})
+
 		When("The CLI is invoked with an invalid database instance", func() {
 			It("Should fail and inform the user about the invalid database instance", func() {
 				os.Args = []string{"nav", "-s", "symbol", "-i", "-1"}
@@ -103,6 +219,7 @@ var _ = Describe("Config Tests", func() {
 				DBInstance:     1,
 				MaxDepth:       1,
 				Mode:           2,
+				Graphviz:       1,
 			}
 		})
 
@@ -136,4 +253,141 @@ var _ = Describe("Config Tests", func() {
 			})
 		})
 	})
+
+	Describe("Environment and profiles", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = os.MkdirTemp("", "navconf")
+			Expect(err).To(BeNil())
+			profiles := `{"prod": {"db_driver": "sqlite3", "DBDSN": "file:prod.db", "db_instance": 4}, "latest": {"instance": "latest"}}`
+			Expect(os.WriteFile(filepath.Join(dir, "profiles.json"), []byte(profiles), 0600)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+			for _, v := range []string{"NAV_SYMBOL", "NAV_DBDSN", "NAV_MAX_DEPTH", "NAV_EXCLUDED_AFTER", "NAV_PROFILE", "NAV_INSTANCE", "NAV_DB_INSTANCE", "NAV_UNKNOWN"} {
+				os.Unsetenv(v)
+			}
+		})
+
+		It("Should take the configuration from the environment", func() {
+			os.Setenv("NAV_SYMBOL", "start_kernel")
+			os.Setenv("NAV_DBDSN", "file:env.db")
+			os.Setenv("NAV_MAX_DEPTH", "3")
+			os.Setenv("NAV_EXCLUDED_AFTER", "a,b")
+			os.Args = []string{"nav"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Symbol).To(Equal("start_kernel"))
+			Expect(conf.DBDSN).To(Equal("file:env.db"))
+			Expect(conf.MaxDepth).To(Equal(3))
+			Expect(conf.ExcludedAfter).To(Equal([]string{"a", "b"}))
+		})
+
+		It("Should let the command line override the environment", func() {
+			os.Setenv("NAV_SYMBOL", "start_kernel")
+			os.Args = []string{"nav", "-s", "do_exit"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Symbol).To(Equal("do_exit"))
+		})
+
+		It("Should report invalid environment values", func() {
+			os.Setenv("NAV_MAX_DEPTH", "deep")
+			os.Args = []string{"nav", "-s", "symbol"}
+
+			_, err := initConfig()
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(HavePrefix("error: invalid value for NAV_MAX_DEPTH"))
+		})
+
+		It("Should take the connection from the selected profile", func() {
+			os.Args = []string{"nav", "-s", "symbol", "--profiles-file", filepath.Join(dir, "profiles.json"), "--profile", "prod"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.DBDriver).To(Equal("sqlite3"))
+			Expect(conf.DBDSN).To(Equal("file:prod.db"))
+			Expect(conf.DBInstance).To(Equal(4))
+		})
+
+		It("Should let the environment override the profile", func() {
+			os.Setenv("NAV_PROFILE", "prod")
+			os.Setenv("NAV_DBDSN", "file:env.db")
+			os.Args = []string{"nav", "-s", "symbol", "--profiles-file", filepath.Join(dir, "profiles.json")}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.DBDriver).To(Equal("sqlite3"))
+			Expect(conf.DBDSN).To(Equal("file:env.db"))
+		})
+
+		It("Should let an instance of the command line override the selector of the profile or environment", func() {
+			os.Args = []string{"nav", "-s", "symbol", "--profiles-file", filepath.Join(dir, "profiles.json"), "--profile", "latest", "-i", "2"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Instance).To(BeEmpty())
+			Expect(conf.DBInstance).To(Equal(2))
+
+			os.Setenv("NAV_INSTANCE", "6.1.55")
+			os.Args = []string{"nav", "-s", "symbol", "--db-instance", "3"}
+
+			conf, err = initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Instance).To(BeEmpty())
+			Expect(conf.DBInstance).To(Equal(3))
+		})
+
+		It("Should let an instance of the environment override the selector of the profile", func() {
+			os.Setenv("NAV_DB_INSTANCE", "5")
+			os.Args = []string{"nav", "-s", "symbol", "--profiles-file", filepath.Join(dir, "profiles.json"), "--profile", "latest"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Instance).To(BeEmpty())
+			Expect(conf.DBInstance).To(Equal(5))
+		})
+
+		It("Should keep the selector given along with the instance", func() {
+			os.Setenv("NAV_INSTANCE", "latest")
+			os.Args = []string{"nav", "-s", "symbol", "-i", "2", "--instance", "6.1.55"}
+
+			conf, err := initConfig()
+
+			Expect(err).To(BeNil())
+			Expect(conf.Instance).To(Equal("6.1.55"))
+		})
+
+		It("Should only consider the known variables as configuration", func() {
+			os.Setenv("NAV_UNKNOWN", "1")
+			os.Args = []string{"nav"}
+
+			_, err := initConfig()
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(HavePrefix("error: no configuration was specified"))
+		})
+
+		It("Should fail for an unknown profile", func() {
+			os.Args = []string{"nav", "-s", "symbol", "--profiles-file", filepath.Join(dir, "profiles.json"), "--profile", "dev"}
+
+			_, err := initConfig()
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(HavePrefix("error: profile dev not found"))
+		})
+	})
 })
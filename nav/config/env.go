@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Prefix of the environment variables overriding configuration fields.
+const envPrefix = "NAV_"
+
+// Returns the name of the environment variable overriding a field, given its
+// json name, i.e. NAV_DBDSN for DBDSN, NAV_DB_DRIVER for db_driver.
+func envName(jsonName string) string {
+	return envPrefix + strings.ToUpper(jsonName)
+}
+
+// Returns true if any environment variable holds configuration.
+func envConfigured() bool {
+	t := reflect.TypeOf(ConfValues{})
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		if _, ok := os.LookupEnv(envName(name)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Sets the fields for which an environment variable is defined.
+// Lists are comma separated.
+func setEnv(cfg *ConfValues) error {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		value, ok := os.LookupEnv(envName(name))
+		if !ok {
+			continue
+		}
+		if err := setValue(v.Field(i), value); err != nil {
+			return fmt.Errorf("invalid value for %s: %w", envName(name), err)
+		}
+	}
+	// a db_instance replaces the instance selector of the previous sources.
+	if _, ok := os.LookupEnv(envName("db_instance")); ok {
+		if _, ok := os.LookupEnv(envName("instance")); !ok {
+			cfg.Instance = ""
+		}
+	}
+	return nil
+}
+
+// Sets a field from its string representation.
+func setValue(f reflect.Value, value string) error {
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(value)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(i)
+	case reflect.Float64:
+		x, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(x)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Slice:
+		var list []string
+		if value != "" {
+			list = strings.Split(value, ",")
+		}
+		f.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("unsupported type %s", f.Kind())
+	}
+	return nil
+}
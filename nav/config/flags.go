@@ -13,7 +13,8 @@ func initFlagSet(fs *pflag.FlagSet, configPath *string) {
 	fs.StringVarP(configPath, "config", "f", "", "path to `config` file")
 
 	fs.StringP("symbol", "s", "", "name of the `symbol` to start the navigation from")
-	fs.StringP("output-type", "j", c.DefaultOutputType, "`type` of output: graphOnly, jsonOutputPlain, jsonOutputB64 or jsonOutputGZB64")
+	fs.StringP("output-type", "j", c.DefaultOutputType, "`type` of output: graphOnly, jsonOutputPlain, jsonOutputB64, jsonOutputGZB64, jsonLines, cflow,\n"+
+		"csvEdges, tsvEdges, csvNodes or tsvNodes")
 	fs.IntP("max-depth", "x", c.DefaultMaxDepth, "max `number` of levels in call flow exploration (0=No limit)")
 	fs.IntP("mode", "m", int(c.DefaultMode), "`mode` of plotting: 1=Symbols, 2=Subsystems, 3=Subsystems with labels, 4=Target subsystem isolation")
 	fs.StringSliceP("excluded-before", "b", nil, "list of `symbols` to exclude before the target symbol")
@@ -22,7 +23,45 @@ func initFlagSet(fs *pflag.FlagSet, configPath *string) {
 
 	fs.StringP("db-driver", "e", c.DefaultDBDriver, "database `driver`: mysql, postgres or sqlite3")
 	fs.IntP("db-instance", "i", c.DefaultDBInstance, "database `instance`")
-	fs.IntP("output-format", "g", int(c.DefaultGOutputType), "Output format 1=dot 2=png 3=jpg 4=svg")
+	fs.String("profile", "", "name of the database `profile` to use, from the profiles file")
+	fs.String("profiles-file", "", "`path` of the profiles file (default ~/.config/nav/profiles.json)")
+	fs.String("instance", "", "database instance `selector`: version string, note, latest or latest:<regexp> (overrides db-instance)")
+	fs.Bool("list-instances", false, "list the database instances and exit")
+	fs.String("report", "", "instance wide `report` to produce instead of a call graph: matrix, surface, layering, cycles,\n"+
+		"unreferenced, syscalls, impact, annotations, traceability or requirements")
+	fs.String("report-format", "", "report output `format`: csv, json or dot for matrix; text, json or csv for surface;\n"+
+		"text, json or junit for layering; text or json for cycles;\n"+
+		"text, json or csv for unreferenced; text, json or dot for syscalls;\n"+
+		"text, json or dot for impact; text, json or csv for annotations; markdown, html or csv for traceability;\n"+
+		"text, json or csv for requirements\n"+
+		"(default is the first)")
+	fs.String("rules", "", "`file` of the layering rules checked by the layering report")
+	fs.String("annotations", "", "`file` of the annotations shown on the outputs and propagated by the annotations report")
+	fs.StringSlice("feature-symbols", nil, "list of the entry `symbols` of the feature of the traceability report (default symbol)")
+	fs.String("feature-name", "", "`name` of the feature of the traceability report")
+	fs.String("requirements", "", "YAML or CSV `file` mapping the requirements to functions, used by the requirements report")
+	fs.String("patch", "", "unified diff `file` whose impact is analyzed by the impact report")
+	fs.String("arch", "", "`architecture` of the syscalls: x86_64, arm64, riscv, s390x, powerpc or generic (default all)")
+	fs.StringSlice("syscall-patterns", nil, "list of `regexps` of the syscall names, overriding the arch ones")
+	fs.StringSlice("entry-points", nil, "list of `regexps` of further entry points, not reported by the unreferenced report")
+	fs.IntP("output-format", "g", int(c.DefaultGOutputType), "Output format 1=dot 2=png 3=jpg 4=svg 5=pdf")
+	fs.String("layout", "", "graphviz layout `engine`: dot, fdp, sfdp or neato (default dot)")
+	fs.String("size", "", "graphviz drawing `size` in inches, i.e. \"7.5,10\"")
+	fs.Float64("dpi", 0, "`resolution` of the rendered image")
+	fs.BoolP("reverse", "r", false, "with the cflow output, show the tree of the callers")
+	fs.BoolP("edge-weights", "w", false, "show the number of calls behind each edge")
+	fs.String("cluster-by", "", "in mode 1, group functions by `criterion`: subsys or dir")
+	fs.String("url-template", "", "in mode 1, link functions and calls to `url`, i.e. file:///src/{file}#L{line}; {line} is set only for calls")
+	fs.Bool("tooltips", false, "in mode 1, add file, subsystem and address tooltips")
+	fs.String("source-tree", "", "kernel source `directory` used to show the call sites")
+	fs.String("source-prefix", "", "build `path` removed from the recorded file names before looking into the source tree")
+	fs.Int("snippet-lines", 0, "`number` of source lines shown around each call site (0=3)")
+	fs.String("html-report", "", "write an html report of the call sites to `file`")
+	fs.Int("max-nodes", 0, "max `number` of functions to explore, breadth-first (0=No limit)")
+	fs.Int("max-edges", 0, "max `number` of edges to produce, breadth-first (0=No limit)")
+	fs.Int("timeout", 0, "max `seconds` spent exploring, breadth-first (0=No limit)")
+	fs.String("cache-dir", "", "`directory` where query results are kept across runs (empty=No persistent cache)")
+	fs.Int("workers", 0, "`number` of concurrent database queries, breadth-first (0,1=sequential)")
 	fs.StringP("DBDSN", "d", "", "database `DSN` in the engine specific format\n"+
 		"postgres: \"host=dbhost.com port=5432 user=username password=<password> dbname=kernel_bin sslmode=disable\"\n"+
 		"mysql: \"username:@tcp(dbhost.com:3306)/dbname?multiStatements=true\"\n"+
@@ -54,17 +93,49 @@ func parseCommandLine(fs *pflag.FlagSet, configPath *string, args []string) erro
 // If a flag is set, set the corresponding field in the config struct.
 func setFlags(fs *pflag.FlagSet, cfg *ConfValues) {
 	var flagToField = map[string]interface{}{
-		"symbol":          &cfg.Symbol,
-		"output-type":     &cfg.Type,
-		"max-depth":       &cfg.MaxDepth,
-		"mode":            &cfg.Mode,
-		"excluded-before": &cfg.ExcludedBefore,
-		"excluded-after":  &cfg.ExcludedAfter,
-		"target-subsys":   &cfg.TargetSubsys,
-		"db-driver":       &cfg.DBDriver,
-		"DBDSN":           &cfg.DBDSN,
-		"db-instance":     &cfg.DBInstance,
-		"output-format":   &cfg.Graphviz,
+		"symbol":           &cfg.Symbol,
+		"output-type":      &cfg.Type,
+		"max-depth":        &cfg.MaxDepth,
+		"mode":             &cfg.Mode,
+		"excluded-before":  &cfg.ExcludedBefore,
+		"excluded-after":   &cfg.ExcludedAfter,
+		"target-subsys":    &cfg.TargetSubsys,
+		"db-driver":        &cfg.DBDriver,
+		"DBDSN":            &cfg.DBDSN,
+		"db-instance":      &cfg.DBInstance,
+		"instance":         &cfg.Instance,
+		"profile":          &cfg.Profile,
+		"profiles-file":    &cfg.ProfilesFile,
+		"list-instances":   &cfg.ListInstances,
+		"report":           &cfg.Report,
+		"report-format":    &cfg.ReportFormat,
+		"rules":            &cfg.RulesFile,
+		"entry-points":     &cfg.EntryPoints,
+		"patch":            &cfg.Patch,
+		"annotations":      &cfg.Annotations,
+		"feature-symbols":  &cfg.FeatureSymbols,
+		"feature-name":     &cfg.FeatureName,
+		"requirements":     &cfg.Requirements,
+		"arch":             &cfg.Arch,
+		"syscall-patterns": &cfg.SyscallRegexps,
+		"output-format":    &cfg.Graphviz,
+		"edge-weights":     &cfg.EdgeWeights,
+		"reverse":          &cfg.Reverse,
+		"cluster-by":       &cfg.ClusterBy,
+		"url-template":     &cfg.URLTemplate,
+		"tooltips":         &cfg.Tooltips,
+		"layout":           &cfg.Layout,
+		"size":             &cfg.Size,
+		"dpi":              &cfg.DPI,
+		"source-tree":      &cfg.SourceTree,
+		"source-prefix":    &cfg.SourcePrefix,
+		"snippet-lines":    &cfg.SnippetLines,
+		"html-report":      &cfg.HTMLReport,
+		"max-nodes":        &cfg.MaxNodes,
+		"max-edges":        &cfg.MaxEdges,
+		"timeout":          &cfg.Timeout,
+		"workers":          &cfg.Workers,
+		"cache-dir":        &cfg.CacheDir,
 	}
 
 	fs.VisitAll(func(f *pflag.Flag) {
@@ -74,6 +145,10 @@ func setFlags(fs *pflag.FlagSet, cfg *ConfValues) {
 			}
 		}
 	})
+	// a db-instance replaces the instance selector of the other sources.
+	if fs.Changed("db-instance") && !fs.Changed("instance") {
+		cfg.Instance = ""
+	}
 }
 
 func setFlag(field interface{}, value pflag.Value) {
@@ -84,6 +159,14 @@ func setFlag(field interface{}, value pflag.Value) {
 		if i, err := strconv.Atoi(value.String()); err == nil {
 			*f = i
 		}
+	case *float64:
+		if v, err := strconv.ParseFloat(value.String(), 64); err == nil {
+			*f = v
+		}
+	case *bool:
+		if b, err := strconv.ParseBool(value.String()); err == nil {
+			*f = b
+		}
 	case *[]string:
 		if sl, ok := value.(pflag.SliceValue); ok {
 			*f = sl.GetSlice()
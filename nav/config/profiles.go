@@ -0,0 +1,65 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Named database connection, stored in the user profiles file.
+type Profile struct {
+	DBDriver   string `json:"db_driver"`
+	DBDSN      string `json:"DBDSN"`
+	DBInstance int    `json:"db_instance"`
+	Instance   string `json:"instance"`
+}
+
+// Returns the default location of the profiles file, in the user
+// configuration directory, i.e. ~/.config/nav/profiles.json.
+func defaultProfilesPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "nav", "profiles.json")
+}
+
+// Returns the profile called name from the profiles file at path.
+func loadProfile(path string, name string) (Profile, error) {
+	var profiles map[string]Profile
+
+	if path == "" {
+		path = defaultProfilesPath()
+	}
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return Profile{}, fmt.Errorf("problem while opening profiles file: %w", err)
+	}
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return Profile{}, fmt.Errorf("problem while parsing profiles file %s: %w", path, err)
+	}
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %s not found in %s", name, path)
+	}
+	return p, nil
+}
+
+// Sets the connection fields defined by the profile. A db_instance of the
+// profile replaces the instance selector of the config file.
+func (p Profile) apply(cfg *ConfValues) {
+	if p.DBDriver != "" {
+		cfg.DBDriver = p.DBDriver
+	}
+	if p.DBDSN != "" {
+		cfg.DBDSN = p.DBDSN
+	}
+	if p.DBInstance != 0 {
+		cfg.DBInstance = p.DBInstance
+		cfg.Instance = ""
+	}
+	if p.Instance != "" {
+		cfg.Instance = p.Instance
+	}
+}
@@ -1,8 +1,9 @@
 package constants
 
 const (
-	OSExitSuccess = 0
-	OSExitError   = -1
+	OSExitSuccess    = 0
+	OSExitError      = -1
+	OSExitViolations = 1
 )
 
 type OutMode int64
@@ -14,6 +15,7 @@ const (
 	OPNG
 	OJPG
 	OSVG
+	OPDF
 	OutIModeLast
 )
 
@@ -36,6 +38,12 @@ const (
 	JsonOutputPlain
 	JsonOutputB64
 	JsonOutputGZB64
+	JsonLines
+	Cflow
+	CsvEdges
+	TsvEdges
+	CsvNodes
+	TsvNodes
 )
 
 // Configuration defaults.
@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"nav/config"
+)
+
+type cycleFunction struct {
+	Symbol string `json:"symbol"`
+	File   string `json:"file"`
+}
+
+// Call site between two functions of a cycle.
+type cycleCall struct {
+	Caller     string `json:"caller"`
+	Callee     string `json:"callee"`
+	SourceLine string `json:"source_line"`
+	Address    string `json:"address"`
+}
+
+// Functions calling each other, with the call sites closing the cycle.
+type cycle struct {
+	Functions []cycleFunction `json:"functions"`
+	Calls     []cycleCall     `json:"calls"`
+}
+
+type cyclesReport struct {
+	Recursive []*cycle `json:"recursive"`
+	Cycles    []*cycle `json:"cycles"`
+}
+
+// Call graph of an instance, in memory as adjacency lists.
+type callGraph struct {
+	functions map[int]cycleFunction
+	succ      map[int][]int
+}
+
+func loadCallGraph(d instanceSource, instance int) (*callGraph, error) {
+	g := &callGraph{map[int]cycleFunction{}, map[int][]int{}}
+	last := [2]int{-1, -1}
+
+	err := d.calls(instance, func(cl call) error {
+		g.functions[cl.callerId] = cycleFunction{cl.caller, cl.callerFile}
+		g.functions[cl.calleeId] = cycleFunction{cl.callee, cl.calleeFile}
+		// calls are sorted by caller and callee, duplicates are adjacent.
+		if last != [2]int{cl.callerId, cl.calleeId} {
+			g.succ[cl.callerId] = append(g.succ[cl.callerId], cl.calleeId)
+			last = [2]int{cl.callerId, cl.calleeId}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// Returns the functions reachable from the ones named symbol.
+func (g *callGraph) reachable(symbol string) (map[int]bool, error) {
+	res := map[int]bool{}
+	var queue []int
+
+	for id, f := range g.functions {
+		if f.Symbol == symbol {
+			res[id] = true
+			queue = append(queue, id)
+		}
+	}
+	if len(queue) == 0 {
+		return nil, fmt.Errorf("symbol %s has no calls in the instance", symbol)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range g.succ[id] {
+			if !res[next] {
+				res[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+	return res, nil
+}
+
+// Returns the strongly connected components of the graph, restricted to
+// the nodes in scope when it is not nil. The iterative form of Tarjan's
+// algorithm is used, since call chains can be very deep.
+func (g *callGraph) components(scope map[int]bool) [][]int {
+	type frame struct{ id, next int }
+	index := map[int]int{}
+	low := map[int]int{}
+	onStack := map[int]bool{}
+	var stack []int
+	var res [][]int
+
+	ids := make([]int, 0, len(g.functions))
+	for id := range g.functions {
+		if scope == nil || scope[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	for _, root := range ids {
+		if _, ok := index[root]; ok {
+			continue
+		}
+		frames := []frame{{root, 0}}
+		index[root], low[root] = len(index), len(index)
+		stack = append(stack, root)
+		onStack[root] = true
+		for len(frames) > 0 {
+			f := &frames[len(frames)-1]
+			if f.next < len(g.succ[f.id]) {
+				w := g.succ[f.id][f.next]
+				f.next++
+				if scope != nil && !scope[w] {
+					continue
+				}
+				if _, ok := index[w]; !ok {
+					index[w], low[w] = len(index), len(index)
+					stack = append(stack, w)
+					onStack[w] = true
+					frames = append(frames, frame{w, 0})
+				} else if onStack[w] && index[w] < low[f.id] {
+					low[f.id] = index[w]
+				}
+				continue
+			}
+			id := f.id
+			frames = frames[:len(frames)-1]
+			if len(frames) > 0 && low[id] < low[frames[len(frames)-1].id] {
+				low[frames[len(frames)-1].id] = low[id]
+			}
+			if low[id] == index[id] {
+				var comp []int
+				for {
+					w := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					onStack[w] = false
+					comp = append(comp, w)
+					if w == id {
+						break
+					}
+				}
+				res = append(res, comp)
+			}
+		}
+	}
+	return res
+}
+
+func lessCycleFunction(a, b cycleFunction) bool {
+	if a.Symbol != b.Symbol {
+		return a.Symbol < b.Symbol
+	}
+	return a.File < b.File
+}
+
+// Returns the directly recursive functions and the cycles of mutually
+// recursive functions of an instance, or of the part of it reachable from
+// symbol, when not empty.
+func findCycles(d instanceSource, instance int, symbol string) (*cyclesReport, error) {
+	var scope map[int]bool
+
+	g, err := loadCallGraph(d, instance)
+	if err != nil {
+		return nil, err
+	}
+	if symbol != "" {
+		if scope, err = g.reachable(symbol); err != nil {
+			return nil, err
+		}
+	}
+
+	// Cycle of each function, and the cycles of the recursive functions.
+	cycleOf := map[int]*cycle{}
+	recursive := map[int]*cycle{}
+	report := &cyclesReport{Recursive: []*cycle{}, Cycles: []*cycle{}}
+	for _, comp := range g.components(scope) {
+		if len(comp) == 1 {
+			continue
+		}
+		cy := &cycle{Calls: []cycleCall{}}
+		for _, id := range comp {
+			cy.Functions = append(cy.Functions, g.functions[id])
+			cycleOf[id] = cy
+		}
+		sort.Slice(cy.Functions, func(i, j int) bool { return lessCycleFunction(cy.Functions[i], cy.Functions[j]) })
+		report.Cycles = append(report.Cycles, cy)
+	}
+
+	err = d.calls(instance, func(cl call) error {
+		if scope != nil && !scope[cl.callerId] {
+			return nil
+		}
+		site := cycleCall{cl.caller, cl.callee, cl.sourceRef, cl.addressRef}
+		if cl.callerId == cl.calleeId {
+			cy, ok := recursive[cl.callerId]
+			if !ok {
+				cy = &cycle{Functions: []cycleFunction{g.functions[cl.callerId]}}
+				recursive[cl.callerId] = cy
+				report.Recursive = append(report.Recursive, cy)
+			}
+			cy.Calls = append(cy.Calls, site)
+		} else if cy := cycleOf[cl.callerId]; cy != nil && cy == cycleOf[cl.calleeId] {
+			cy.Calls = append(cy.Calls, site)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, list := range [][]*cycle{report.Recursive, report.Cycles} {
+		for _, cy := range list {
+			sort.SliceStable(cy.Calls, func(i, j int) bool {
+				if cy.Calls[i].Caller != cy.Calls[j].Caller {
+					return cy.Calls[i].Caller < cy.Calls[j].Caller
+				}
+				return cy.Calls[i].Callee < cy.Calls[j].Callee
+			})
+		}
+		sort.SliceStable(list, func(i, j int) bool { return lessCycleFunction(list[i].Functions[0], list[j].Functions[0]) })
+	}
+	return report, nil
+}
+
+func writeCyclesText(w io.Writer, report *cyclesReport) error {
+	section := func(title string, list []*cycle) error {
+		if _, err := fmt.Fprintf(w, "%s: %d\n", title, len(list)); err != nil {
+			return err
+		}
+		for _, cy := range list {
+			var names []string
+			for _, f := range cy.Functions {
+				names = append(names, fmt.Sprintf("%s (%s)", f.Symbol, f.File))
+			}
+			if _, err := fmt.Fprintf(w, "  %s\n", strings.Join(names, ", ")); err != nil {
+				return err
+			}
+			for _, cl := range cy.Calls {
+				if _, err := fmt.Fprintf(w, "    %s -> %s at %s %s\n", cl.Caller, cl.Callee, cl.SourceLine, cl.Address); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	if err := section("recursive functions", report.Recursive); err != nil {
+		return err
+	}
+	return section("cycles", report.Cycles)
+}
+
+func writeCyclesReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	report, err := findCycles(d, conf.DBInstance, conf.Symbol)
+	if err != nil {
+		return err
+	}
+	if conf.ReportFormat == "json" {
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(report)
+	}
+	return writeCyclesText(w, report)
+}
@@ -0,0 +1,91 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Cycles Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		// start -> a <-> b, b -> c -> c, other -> d -> e -> d
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "start", "s.c", "a", "a.c", "s.c:1", "0x1"},
+				{2, 3, "a", "a.c", "b", "b.c", "a.c:5", "0x5"},
+				{3, 2, "b", "b.c", "a", "a.c", "b.c:7", "0x7"},
+				{3, 4, "b", "b.c", "c", "c.c", "b.c:8", "0x8"},
+				{4, 4, "c", "c.c", "c", "c.c", "c.c:2", "0x2"},
+				{4, 4, "c", "c.c", "c", "c.c", "c.c:3", "0x3"},
+				{5, 6, "other", "o.c", "d", "d.c", "o.c:1", "0x11"},
+				{6, 7, "d", "d.c", "e", "e.c", "d.c:4", "0x14"},
+				{7, 6, "e", "e.c", "d", "d.c", "e.c:9", "0x19"},
+			},
+		}
+	})
+
+	It("Should find the recursive functions and the cycles of an instance", func() {
+		report, err := findCycles(src, 1, "")
+
+		Expect(err).To(BeNil())
+		Expect(report.Recursive).To(Equal([]*cycle{{
+			Functions: []cycleFunction{{"c", "c.c"}},
+			Calls:     []cycleCall{{"c", "c", "c.c:2", "0x2"}, {"c", "c", "c.c:3", "0x3"}},
+		}}))
+		Expect(report.Cycles).To(Equal([]*cycle{
+			{
+				Functions: []cycleFunction{{"a", "a.c"}, {"b", "b.c"}},
+				Calls:     []cycleCall{{"a", "b", "a.c:5", "0x5"}, {"b", "a", "b.c:7", "0x7"}},
+			},
+			{
+				Functions: []cycleFunction{{"d", "d.c"}, {"e", "e.c"}},
+				Calls:     []cycleCall{{"d", "e", "d.c:4", "0x14"}, {"e", "d", "e.c:9", "0x19"}},
+			},
+		}))
+	})
+
+	It("Should limit the search to the functions reachable from a symbol", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "cycles", ReportFormat: "text", Symbol: "b"}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("recursive functions: 1\n" +
+			"  c (c.c)\n" +
+			"    c -> c at c.c:2 0x2\n" +
+			"    c -> c at c.c:3 0x3\n" +
+			"cycles: 1\n" +
+			"  a (a.c), b (b.c)\n" +
+			"    a -> b at a.c:5 0x5\n" +
+			"    b -> a at b.c:7 0x7\n"))
+	})
+
+	It("Should fail on a symbol without calls", func() {
+		_, err := findCycles(src, 1, "missing")
+
+		Expect(err).To(MatchError("symbol missing has no calls in the instance"))
+	})
+
+	It("Should follow very long call chains", func() {
+		long := &fakeInstance{}
+		for i := 1; i < 100000; i++ {
+			long.list = append(long.list, call{callerId: i, calleeId: i + 1, caller: "f", callee: "f"})
+		}
+		long.list = append(long.list, call{callerId: 100000, calleeId: 1, caller: "f", callee: "f"})
+
+		report, err := findCycles(long, 1, "")
+
+		Expect(err).To(BeNil())
+		Expect(report.Cycles).To(HaveLen(1))
+		Expect(report.Cycles[0].Functions).To(HaveLen(100000))
+	})
+})
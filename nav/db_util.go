@@ -6,16 +6,17 @@
 package main
 
 import (
-	"fmt"
+	"nav/config"
+	c "nav/constants"
 	"regexp"
 	"sort"
-	c "nav/constants"
 )
 
 type Datasource interface {
 	init(arg interface{}) (err error)
 	GetExploredSubsystemByName(subs string) string
 	getSuccessorsById(symbolId int, instance int) ([]entry, error)
+	getPredecessorsById(symbolId int, instance int) ([]entry, error)
 	getSubsysFromSymbolName(symbol string, instance int) (string, error)
 	sym2num(symb string, instance int) (int, error)
 	symbSubsys(symblist []int, instance int) (string, error)
@@ -46,6 +47,7 @@ type entry struct {
 	addressRef string
 	subsys     []string
 	symId      int
+	address    string
 }
 
 type edge struct {
@@ -81,6 +83,24 @@ func removeDuplicate(list []entry) []entry {
 	return res
 }
 
+// Returns a copy of list sorted by function name, id and call address, so
+// that the output does not depend on the order the rows come from the
+// database.
+func sortEntries(list []entry) []entry {
+	res := make([]entry, len(list))
+	copy(res, list)
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].symbol != res[j].symbol {
+			return res[i].symbol < res[j].symbol
+		}
+		if res[i].symId != res[j].symId {
+			return res[i].symId < res[j].symId
+		}
+		return res[i].addressRef < res[j].addressRef
+	})
+	return res
+}
+
 // Checks if a given function needs to be explored.
 func notExcluded(symbol string, excluded []string) bool {
 	for _, s := range excluded {
@@ -91,40 +111,221 @@ func notExcluded(symbol string, excluded []string) bool {
 	return true
 }
 
-// Computes the call tree of a given function name
-// TODO: refactory needed:
-// What is the problem: too many args.
-// suggestion: New version with input and output structs.
-func navigate(d Datasource, symbolId int, parentDispaly node, targets []string, visited *[]int, AdjMap *[]adjM, prod map[string]int, instance int, mode c.OutMode, excludedAfter []string, excludedBefore []string, depth int, maxdepth int, dotFmt string, output *string, archnum *int) {
-	var tmp, s string
+// Edge of the output graph, collected while navigating the call tree.
+type graphEdge struct {
+	l         node
+	r         node
+	id        int
+	depth     int
+	callSites int
+	pairs     map[string]bool
+	sites     []callSite
+}
+
+// Source line and address of a call.
+type callSite struct {
+	sourceRef  string
+	addressRef string
+}
+
+// Returns the number of distinct caller/callee symbol pairs behind the edge.
+func (e *graphEdge) symPairs() int {
+	return len(e.pairs)
+}
+
+// Reasons for a node to be highlighted in the output graph.
+const (
+	nodeExcluded    = "excluded"
+	nodeNotExplored = "not explored"
+)
+
+// Node of the output graph whose successors have not been explored.
+type graphNode struct {
+	symbol string
+	status string
+}
+
+// Navigation state shared across the recursive calls of navigate.
+// If sink is set, edges and nodes are passed to it as soon as they are
+// created; this is meaningful only in modes where edges are final at
+// creation, i.e. PrintAll. In such case, unless retain is set because the
+// output needs them after the traversal, edges are not kept: only their keys
+// are, in sent, and the visit order is not kept either.
+type navState struct {
+	visited   map[int]bool
+	order     []int
+	adjMap    []adjM
+	prod      map[string]*graphEdge
+	sent      map[string]bool
+	edges     []*graphEdge
+	nodes     []graphNode
+	archnum   int
+	truncated string
+	sink      graphSink
+	err       error
+	info      map[string]nodeInfo
+	symbols   []string
+	retain    bool
+}
+
+// Details of a function in the output graph.
+type nodeInfo struct {
+	subsys     string
+	file       string
+	subsystems []string
+	address    string
+}
+
+func newNavState() *navState {
+	return &navState{visited: map[int]bool{}, prod: map[string]*graphEdge{}, sent: map[string]bool{}, info: map[string]nodeInfo{}}
+}
+
+// Marks a function as visited, keeping the visit order unless edges are
+// streamed.
+func (st *navState) visit(symbolId int) {
+	st.visited[symbolId] = true
+	if st.sink == nil || st.retain {
+		st.order = append(st.order, symbolId)
+	}
+}
+
+// Records the details of a function the first time it is met.
+// The functions are also kept in the order they are met.
+func (st *navState) addInfo(symbol string, subsys string, e entry) {
+	if _, ok := st.info[symbol]; !ok {
+		st.info[symbol] = nodeInfo{subsys, e.fn, e.subsys, e.address}
+		st.symbols = append(st.symbols, symbol)
+	}
+}
+
+// Accounts the calls at sites from l to r on the edge identified by key.
+// The edge is created the first time it is seen.
+// With a sink, the edge is passed to it, and unless retain is set dropped;
+// later calls on the same edge are then ignored, as it has been written.
+func (st *navState) addEdge(key string, l node, r node, depth int, sites []callSite) {
+	if st.sink != nil && !st.retain {
+		if st.sent[key] {
+			return
+		}
+		st.sent[key] = true
+		st.archnum++
+		e := &graphEdge{l: l, r: r, id: st.archnum, depth: depth, callSites: len(sites), pairs: map[string]bool{l.symbol + "->" + r.symbol: true}, sites: sites}
+		st.setErr(st.sink.edge(e))
+		return
+	}
+	e, ok := st.prod[key]
+	if !ok {
+		st.archnum++
+		e = &graphEdge{l: l, r: r, id: st.archnum, depth: depth, pairs: map[string]bool{}}
+		st.prod[key] = e
+		st.edges = append(st.edges, e)
+		e.callSites += len(sites)
+		e.sites = append(e.sites, sites...)
+		e.pairs[l.symbol+"->"+r.symbol] = true
+		if st.sink != nil {
+			st.setErr(st.sink.edge(e))
+		}
+		return
+	}
+	e.callSites += len(sites)
+	e.sites = append(e.sites, sites...)
+	e.pairs[l.symbol+"->"+r.symbol] = true
+}
+
+// Records a node whose successors are not part of the graph.
+func (st *navState) addNode(n graphNode) {
+	if st.sink != nil {
+		st.setErr(st.sink.node(n))
+		return
+	}
+	st.nodes = append(st.nodes, n)
+}
+
+// Keeps track of the first error occurred while navigating.
+func (st *navState) setErr(err error) {
+	if st.err == nil {
+		st.err = err
+	}
+}
+
+// Returns the distinct call sites for every callee in a successors list, in
+// the order of the list.
+func callSitesOf(list []entry) map[int][]callSite {
+	seen := make(map[int]map[string]bool)
+	res := make(map[int][]callSite)
+	for _, item := range list {
+		if _, ok := seen[item.symId]; !ok {
+			seen[item.symId] = make(map[string]bool)
+		}
+		if !seen[item.symId][item.addressRef] {
+			seen[item.symId][item.addressRef] = true
+			res[item.symId] = append(res[item.symId], callSite{item.sourceRef, item.addressRef})
+		}
+	}
+	return res
+}
+
+// Node waiting to be explored.
+type navItem struct {
+	symId  int
+	parent node
+	depth  int
+}
+
+// Computes the call tree of a given function name.
+func navigate(d Datasource, symbolId int, parentDispaly node, depth int, conf *config.ConfValues, st *navState) {
+	st.visit(symbolId)
+	expand(d, symbolId, parentDispaly, depth, conf, st, func(item navItem) {
+		navigate(d, item.symId, item.parent, item.depth, conf, st)
+	})
+}
+
+// Accounts the edges toward the successors of a given function and calls
+// explore, in order, for each successor that needs to be explored.
+func expand(d Datasource, symbolId int, parentDispaly node, depth int, conf *config.ConfValues, st *navState, explore func(navItem)) {
+	var tmp string
 	var l, r, ll node
 
-	*visited = append(*visited, symbolId)
 	l = parentDispaly
-	successors, err := d.getSuccessorsById(symbolId, instance)
-	if mode == c.PrintAll {
-		successors = removeDuplicate(successors)
+	successors, err := d.getSuccessorsById(symbolId, conf.DBInstance)
+	if err != nil {
+		st.setErr(err)
+		return
+	}
+	successors = sortEntries(successors)
+	callSites := callSitesOf(successors)
+	if conf.Mode == c.PrintAll {
+		successors = sortEntries(removeDuplicate(successors))
 	}
 	if err == nil {
 		for _, curr := range successors {
 			depthInc := 0
-			if notExcluded(curr.symbol, excludedBefore) {
+			if notExcluded(curr.symbol, conf.ExcludedBefore) {
 				r.symbol = curr.symbol
 				r.sourceRef = curr.sourceRef
 				r.addressRef = curr.addressRef
-				tmp, _ = d.getSubsysFromSymbolName(r.symbol, instance)
+				tmp, err = d.getSubsysFromSymbolName(r.symbol, conf.DBInstance)
+				if err != nil {
+					st.setErr(err)
+					return
+				}
 				if tmp == "" {
 					r.subsys = SUBSYS_UNDEF
+					st.addInfo(r.symbol, SUBSYS_UNDEF, curr)
+				} else {
+					if conf.Mode == c.PrintAll {
+						r.subsys = tmp
+					}
+					st.addInfo(r.symbol, tmp, curr)
 				}
 
-				switch mode {
+				switch conf.Mode {
 				case c.PrintAll:
-					(*archnum)++
-					s = fmt.Sprintf(dotFmt, l.symbol, r.symbol, *archnum)
+					st.addEdge(l.symbol+"->"+r.symbol, l, r, depth+1, callSites[curr.symId])
 					ll = r
 					depthInc = 1
 				case c.PrintSubsys, c.PrintSubsysWs, c.PrintTargeted:
-					if tmp, _ = d.getSubsysFromSymbolName(r.symbol, instance); r.subsys != tmp {
+					if tmp, _ = d.getSubsysFromSymbolName(r.symbol, conf.DBInstance); r.subsys != tmp {
 						if tmp != "" {
 							r.subsys = tmp
 						} else {
@@ -133,40 +334,26 @@ func navigate(d Datasource, symbolId int, parentDispaly node, targets []string,
 					}
 
 					if l.subsys != r.subsys {
-						(*archnum)++
-						s = fmt.Sprintf(dotFmt, l.subsys, r.subsys)
-						*AdjMap = append(*AdjMap, adjM{l, r})
+						st.adjMap = append(st.adjMap, adjM{l, r})
 						depthInc = 1
-					} else {
-						s = ""
+						if (conf.Mode != c.PrintTargeted) || (intargets(conf.TargetSubsys, l.subsys, r.subsys)) {
+							st.addEdge(l.subsys+"->"+r.subsys, l, r, depth+1, []callSite{{r.sourceRef, r.addressRef}})
+						}
 					}
 					ll = r
 				default:
-					panic(mode)
-				}
-				if _, ok := prod[s]; ok {
-					prod[s]++
-				} else {
-					prod[s] = 1
-					if s != "" {
-						if (mode != c.PrintTargeted) || (intargets(targets, l.subsys, r.subsys)) {
-							*output = (*output) + s
-						}
-					}
+					panic(conf.Mode)
 				}
-				if notIn(*visited, curr.symId) {
-					if (notExcluded(curr.symbol, excludedAfter) && notExcluded(curr.symbol, excludedBefore)) && (maxdepth == 0 || ((maxdepth > 0) && (depth+depthInc < maxdepth))) {
-						navigate(d, curr.symId, ll, targets, visited, AdjMap, prod, instance, mode, excludedAfter, excludedBefore, depth+depthInc, maxdepth, dotFmt, output, archnum)
+				if !st.visited[curr.symId] {
+					if (notExcluded(curr.symbol, conf.ExcludedAfter) && notExcluded(curr.symbol, conf.ExcludedBefore)) && (conf.MaxDepth == 0 || ((conf.MaxDepth > 0) && (depth+depthInc < conf.MaxDepth))) {
+						explore(navItem{curr.symId, ll, depth + depthInc})
 					} else {
-						if !notExcluded(curr.symbol, excludedAfter) && mode == c.PrintAll {
-							s = fmt.Sprintf("\"%s\" [style=filled; fillcolor=orange];\n", r.symbol)
-							*output = (*output) + s
+						if !notExcluded(curr.symbol, conf.ExcludedAfter) && conf.Mode == c.PrintAll {
+							st.addNode(graphNode{r.symbol, nodeExcluded})
 						} else {
-							tmp, _ := d.getSuccessorsById(curr.symId, instance)
-							if (len(tmp)>0) && (mode==c.PrintAll) {
-//								fmt.Printf("==> %d(%s) succn=%d\n", curr.symId,curr.symbol, len(tmp))
-								s = fmt.Sprintf("\"%s\" [style=filled; fillcolor=red];\n", r.symbol)
-								*output = (*output) + s
+							tmp, _ := d.getSuccessorsById(curr.symId, conf.DBInstance)
+							if (len(tmp) > 0) && (conf.Mode == c.PrintAll) {
+								st.addNode(graphNode{r.symbol, nodeNotExplored})
 							}
 						}
 					}
@@ -5,6 +5,9 @@
 package main
 
 import (
+	"nav/config"
+	c "nav/constants"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 )
@@ -107,8 +110,118 @@ var _ = Describe("Psql Tests", func() {
 		})
 	})
 
+	When("sortEntries", func() {
+		It("Should sort by name, id and call address without modifying the input", func() {
+			list := []entry{
+				{symbol: "b", symId: 2, addressRef: "0x20"},
+				{symbol: "a", symId: 3},
+				{symbol: "b", symId: 2, addressRef: "0x10"},
+			}
+
+			res := sortEntries(list)
+
+			Expect(res).To(Equal([]entry{
+				{symbol: "a", symId: 3},
+				{symbol: "b", symId: 2, addressRef: "0x10"},
+				{symbol: "b", symId: 2, addressRef: "0x20"},
+			}))
+			Expect(list[0].symbol).To(Equal("b"))
+		})
+	})
+
+	When("navigate", func() {
+		It("Should produce the same graph whatever the order of the successors", func() {
+			successors := []entry{
+				{symbol: "c", symId: 3, addressRef: "0x30", sourceRef: "a.c:3"},
+				{symbol: "b", symId: 2, addressRef: "0x20", sourceRef: "a.c:2"},
+				{symbol: "b", symId: 2, addressRef: "0x10", sourceRef: "a.c:1"},
+			}
+			graph := func(list []entry) []*graphEdge {
+				d := &sqlMock{}
+				d.init(nil)
+				d.LOADgetSuccessorsByIdValues(1, 1, list, nil)
+				st := newNavState()
+				conf := config.ConfValues{DBInstance: 1, Mode: c.PrintAll}
+				navigate(d, 1, node{"S0", "a", "entry point", "0x0"}, 0, &conf, st)
+				return st.edges
+			}
+
+			res := graph(successors)
+
+			Expect(graph([]entry{successors[2], successors[0], successors[1]})).To(Equal(res))
+			Expect(res[0].r.symbol).To(Equal("b"))
+			Expect(res[0].r.addressRef).To(Equal("0x10"))
+		})
+	})
+
+	When("callSitesOf", func() {
+		It("Should count distinct call sites per callee", func() {
+			entries := []entry{
+				{symId: 1, addressRef: "0x10"},
+				{symId: 1, addressRef: "0x20"},
+				{symId: 1, addressRef: "0x20"},
+				{symId: 2, addressRef: "0x30"},
+			}
+
+			res := callSitesOf(entries)
+
+			Expect(res).To(Equal(map[int][]callSite{1: {{"", "0x10"}, {"", "0x20"}}, 2: {{"", "0x30"}}}))
+		})
+
+		It("Should return an empty map if using an empty slice", func() {
+			res := callSitesOf([]entry{})
+
+			Expect(res).To(BeEmpty())
+		})
+	})
+
 	When("navigate", func() {
-		// TODO: `psql.navigate` fn refactor needed
+		var d *sqlMock
+		var conf config.ConfValues
+
+		BeforeEach(func() {
+			d = &sqlMock{}
+			d.init(nil)
+			d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+				{symbol: "b", symId: 2, addressRef: "0x10"},
+				{symbol: "b", symId: 2, addressRef: "0x20"},
+				{symbol: "c", symId: 3, addressRef: "0x30"},
+			}, nil)
+			d.LOADgetSuccessorsByIdValues(2, 1, []entry{
+				{symbol: "c", symId: 3, addressRef: "0x40"},
+			}, nil)
+			d.LOADgetSubsysFromSymbolNameValues("b", 1, "S1", nil)
+			d.LOADgetSubsysFromSymbolNameValues("c", 1, "S2", nil)
+			conf = config.ConfValues{DBInstance: 1}
+		})
+
+		It("Should count the call sites behind each symbol edge", func() {
+			conf.Mode = c.PrintAll
+			st := newNavState()
+
+			navigate(d, 1, node{"S0", "a", "entry point", "0x0"}, 0, &conf, st)
+
+			Expect(st.edges).To(HaveLen(3))
+			Expect(st.edges[0].r.symbol).To(Equal("b"))
+			Expect(st.edges[0].callSites).To(Equal(2))
+			Expect(st.edges[1].l.symbol).To(Equal("b"))
+			Expect(st.edges[1].callSites).To(Equal(1))
+			Expect(st.edges[2].r.symbol).To(Equal("c"))
+			Expect(st.edges[2].callSites).To(Equal(1))
+		})
+
+		It("Should count call sites and symbol pairs behind each subsystem edge", func() {
+			conf.Mode = c.PrintSubsys
+			st := newNavState()
+
+			navigate(d, 1, node{"S0", "a", "entry point", "0x0"}, 0, &conf, st)
+
+			Expect(st.edges).To(HaveLen(3))
+			Expect(st.prod["S0->S1"].callSites).To(Equal(2))
+			Expect(st.prod["S0->S1"].symPairs()).To(Equal(1))
+			Expect(st.prod["S1->S2"].callSites).To(Equal(1))
+			Expect(st.prod["S0->S2"].callSites).To(Equal(1))
+		})
 	})
 
 	Describe("intargets", func() {
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Lines of a file changed by a patch, numbered as in the file before the
+// patch, which is what the database describes.
+type fileChange struct {
+	File  string `json:"file"`
+	Lines []int  `json:"lines"`
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -([0-9]+)(,[0-9]+)? \+[0-9]+(,[0-9]+)? @@`)
+
+// Returns the path of a diff header line, without the a/ or b/ prefix of
+// git diffs and the timestamps of diff -u.
+func diffPath(line string) string {
+	path := strings.SplitN(line[4:], "\t", 2)[0]
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		path = path[2:]
+	}
+	return path
+}
+
+// Parses a unified diff, as produced by git diff or diff -u. Removed lines
+// are reported as they are, added lines as the line they follow. Files
+// created by the patch have no lines, since they are not in the database.
+func parseUnifiedDiff(r io.Reader) ([]fileChange, error) {
+	var res []fileChange
+	var cur *fileChange
+	// next line of the old file, and lines left in the current hunk.
+	var old, oldLeft, newLeft int
+	// the current file has no old side.
+	var created bool
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		if oldLeft == 0 && newLeft == 0 {
+			switch {
+			case strings.HasPrefix(line, "--- "):
+				res = append(res, fileChange{File: diffPath(line)})
+				cur = &res[len(res)-1]
+				created = cur.File == ""
+			case strings.HasPrefix(line, "+++ "):
+				if cur == nil {
+					return nil, fmt.Errorf("line %d: +++ without ---", n)
+				}
+				if cur.File == "" {
+					cur.File = diffPath(line)
+				}
+			case strings.HasPrefix(line, "@@ "):
+				m := hunkHeader.FindStringSubmatch(line)
+				if m == nil || cur == nil {
+					return nil, fmt.Errorf("line %d: invalid hunk header: %s", n, line)
+				}
+				old, _ = strconv.Atoi(m[1])
+				oldLeft, newLeft = 1, 1
+				if m[2] != "" {
+					oldLeft, _ = strconv.Atoi(m[2][1:])
+				}
+				if m[3] != "" {
+					newLeft, _ = strconv.Atoi(m[3][1:])
+				}
+				// an empty old range starts after its line.
+				if oldLeft == 0 {
+					old++
+				}
+			}
+			// anything else is commit message, diffstat or git headers.
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "-"):
+			cur.Lines = append(cur.Lines, old)
+			old++
+			oldLeft--
+		case strings.HasPrefix(line, "+"):
+			switch {
+			case created:
+				// not in the database, nothing to map the line on.
+			case old > 1:
+				cur.Lines = append(cur.Lines, old-1)
+			default:
+				cur.Lines = append(cur.Lines, old)
+			}
+			newLeft--
+		case strings.HasPrefix(line, "\\"):
+			// no newline at end of file.
+		default:
+			old++
+			oldLeft--
+			newLeft--
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i := range res {
+		sort.Ints(res[i].Lines)
+		res[i].Lines = uniqueInts(res[i].Lines)
+	}
+	return res, nil
+}
+
+// Removes the duplicates of a sorted list.
+func uniqueInts(list []int) []int {
+	var res []int
+
+	for i, v := range list {
+		if i == 0 || v != list[i-1] {
+			res = append(res, v)
+		}
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Diff Tests", func() {
+	It("Should return the changed lines of the old files", func() {
+		patch := `From 1234 Mon Sep 17 00:00:00 2001
+Subject: [PATCH] fs: fix read
+
+--- a/fs/read.c
++++ b/fs/read.c
+@@ -10,7 +10,7 @@ ssize_t vfs_read(struct file *file)
+ 	int a;
+ 	int b;
+-	int c;
++	long c;
++	long d;
+ 
+--- not a header, a removed line
+ 	return 0;
+ }
+@@ -40,0 +42,1 @@
++	extra();
+diff --git a/Documentation/new.rst b/Documentation/new.rst
+new file mode 100644
+--- /dev/null
++++ b/Documentation/new.rst
+@@ -0,0 +1,2 @@
++Title
++=====
+--- fs/old.c	2024-01-01 00:00:00
++++ fs/old.c	2024-01-02 00:00:00
+@@ -1 +1 @@
+-x
+\ No newline at end of file
++y
+\ No newline at end of file
+`
+		changes, err := parseUnifiedDiff(strings.NewReader(patch))
+
+		Expect(err).To(BeNil())
+		Expect(changes).To(Equal([]fileChange{
+			{"fs/read.c", []int{12, 14, 40}},
+			{"Documentation/new.rst", nil},
+			{"fs/old.c", []int{1}},
+		}))
+	})
+
+	It("Should reject a broken hunk header", func() {
+		_, err := parseUnifiedDiff(strings.NewReader("--- a/x.c\n+++ b/x.c\n@@ -a +b @@\n"))
+
+		Expect(err).To(MatchError("line 3: invalid hunk header: @@ -a +b @@"))
+	})
+})
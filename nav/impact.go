@@ -0,0 +1,325 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nav/config"
+	c "nav/constants"
+)
+
+type changedFunction struct {
+	Symbol string `json:"symbol"`
+	File   string `json:"file"`
+	Lines  []int  `json:"lines"`
+}
+
+type impactedFunction struct {
+	Symbol     string   `json:"symbol"`
+	File       string   `json:"file"`
+	Depth      int      `json:"depth"`
+	Subsystems []string `json:"subsystems"`
+	id         int
+}
+
+// Functions a patch changes, and the ones which may be influenced by the
+// change, calling them up to some depth.
+type impactReport struct {
+	Modified    []*changedFunction  `json:"modified"`
+	Unmapped    []fileChange        `json:"unmapped"`
+	Affected    []*impactedFunction `json:"affected"`
+	Subsystems  []string            `json:"subsystems"`
+	EntryPoints []string            `json:"entry_points"`
+	graph       *callGraph
+	depth       map[int]int
+}
+
+// Lines of a file where a function has call sites.
+type lineRange struct {
+	id, first, last int
+}
+
+// Tells whether a file name recorded in the database is the path of a diff.
+func samePath(recorded, path string) bool {
+	return recorded == path || strings.HasSuffix(recorded, "/"+path)
+}
+
+// Returns the functions owning the changed lines of a file, and the lines
+// no function owns. The database has the lines of the call sites, not of the
+// function definitions: a line belongs to the functions defined in the file
+// whose call sites surround it. Lines out of any range, such as the lines of
+// functions without calls, are not guessed.
+func mapLines(ranges []lineRange, lines []int) (map[int][]int, []int) {
+	res := map[int][]int{}
+	var unmapped []int
+
+	for _, l := range lines {
+		owned := false
+		for _, r := range ranges {
+			if r.first <= l && l <= r.last {
+				res[r.id] = append(res[r.id], l)
+				owned = true
+			}
+		}
+		if !owned {
+			unmapped = append(unmapped, l)
+		}
+	}
+	return res, unmapped
+}
+
+// Returns the impact of the changes on an instance: the changed functions
+// and their callers up to maxDepth levels, when not 0.
+func analyzeImpact(d instanceSource, instance int, changes []fileChange, maxDepth int, extra []string) (*impactReport, error) {
+	report := &impactReport{Modified: []*changedFunction{}, Unmapped: []fileChange{}, Affected: []*impactedFunction{},
+		Subsystems: []string{}, EntryPoints: []string{}, depth: map[int]int{}}
+
+	g, err := loadCallGraph(d, instance)
+	if err != nil {
+		return nil, err
+	}
+	report.graph = g
+
+	// call site lines of every function, in each changed file.
+	ranges := make([]map[int]*lineRange, len(changes))
+	for i := range ranges {
+		ranges[i] = map[int]*lineRange{}
+	}
+	err = d.calls(instance, func(cl call) error {
+		file, line := splitSourceRef(cl.sourceRef)
+		n, err := strconv.Atoi(line)
+		if err != nil {
+			return nil
+		}
+		for i, ch := range changes {
+			// calls of functions defined elsewhere, i.e. inlined from
+			// headers, do not tell where the functions of the file are.
+			if !samePath(file, ch.File) || !samePath(cl.callerFile, ch.File) {
+				continue
+			}
+			r, ok := ranges[i][cl.callerId]
+			if !ok {
+				ranges[i][cl.callerId] = &lineRange{cl.callerId, n, n}
+				continue
+			}
+			if n < r.first {
+				r.first = n
+			}
+			if n > r.last {
+				r.last = n
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var queue []int
+	for i, ch := range changes {
+		var list []lineRange
+		for _, r := range ranges[i] {
+			list = append(list, *r)
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].id < list[j].id })
+		owners, unmapped := mapLines(list, ch.Lines)
+		if len(unmapped) > 0 {
+			report.Unmapped = append(report.Unmapped, fileChange{ch.File, unmapped})
+		}
+		for id, lines := range owners {
+			report.Modified = append(report.Modified, &changedFunction{g.functions[id].Symbol, ch.File, lines})
+			if _, ok := report.depth[id]; !ok {
+				report.depth[id] = 0
+				queue = append(queue, id)
+			}
+		}
+	}
+	sort.Slice(report.Modified, func(i, j int) bool {
+		if report.Modified[i].File != report.Modified[j].File {
+			return report.Modified[i].File < report.Modified[j].File
+		}
+		return report.Modified[i].Symbol < report.Modified[j].Symbol
+	})
+
+	pred := map[int][]int{}
+	for id, list := range g.succ {
+		for _, next := range list {
+			pred[next] = append(pred[next], id)
+		}
+	}
+	sort.Ints(queue)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if maxDepth > 0 && report.depth[id] >= maxDepth {
+			continue
+		}
+		for _, p := range pred[id] {
+			if _, ok := report.depth[p]; !ok {
+				report.depth[p] = report.depth[id] + 1
+				queue = append(queue, p)
+			}
+		}
+	}
+
+	ep, err := newEntryPoints(d, instance, extra)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	subsystems := map[string]bool{}
+	entries := map[string]bool{}
+	for id, depth := range report.depth {
+		f := g.functions[id]
+		fs := subsystemsOf(subs, f.File)
+		report.Affected = append(report.Affected, &impactedFunction{f.Symbol, f.File, depth, fs, id})
+		for _, s := range fs {
+			subsystems[s] = true
+		}
+		if ep.match(f.Symbol) {
+			entries[f.Symbol] = true
+		}
+	}
+	sort.Slice(report.Affected, func(i, j int) bool {
+		a, b := report.Affected[i], report.Affected[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.File < b.File
+	})
+	for s := range subsystems {
+		report.Subsystems = append(report.Subsystems, s)
+	}
+	sort.Strings(report.Subsystems)
+	for e := range entries {
+		report.EntryPoints = append(report.EntryPoints, e)
+	}
+	sort.Strings(report.EntryPoints)
+	return report, nil
+}
+
+func joinInts(list []int) string {
+	var res []string
+
+	for _, n := range list {
+		res = append(res, strconv.Itoa(n))
+	}
+	return strings.Join(res, ", ")
+}
+
+func writeImpactText(w io.Writer, report *impactReport) error {
+	if _, err := fmt.Fprintf(w, "modified functions: %d\n", len(report.Modified)); err != nil {
+		return err
+	}
+	for _, m := range report.Modified {
+		if _, err := fmt.Fprintf(w, "  %s (%s) lines %s\n", m.Symbol, m.File, joinInts(m.Lines)); err != nil {
+			return err
+		}
+	}
+	if len(report.Unmapped) > 0 {
+		if _, err := fmt.Fprintf(w, "unmapped changes: %d\n", len(report.Unmapped)); err != nil {
+			return err
+		}
+		for _, u := range report.Unmapped {
+			if _, err := fmt.Fprintf(w, "  %s lines %s\n", u.File, joinInts(u.Lines)); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := fmt.Fprintf(w, "affected functions: %d\n", len(report.Affected)); err != nil {
+		return err
+	}
+	for _, a := range report.Affected {
+		if _, err := fmt.Fprintf(w, "  %d %s (%s) [%s]\n", a.Depth, a.Symbol, a.File, strings.Join(a.Subsystems, ", ")); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "subsystems: %s\nentry points: %s\n", strings.Join(report.Subsystems, ", "), strings.Join(report.EntryPoints, ", "))
+	return err
+}
+
+// Writes the graph of the affected functions, with the calls leading from
+// one level to the next. Modified functions are red, entry points cyan.
+func writeImpactDot(w io.Writer, report *impactReport) error {
+	var lines []string
+	entries := map[string]bool{}
+
+	for _, e := range report.EntryPoints {
+		entries[e] = true
+	}
+	if _, err := io.WriteString(w, fmtDotHeader[c.PrintAll]); err != nil {
+		return err
+	}
+	for _, a := range report.Affected {
+		color := ""
+		if a.Depth == 0 {
+			color = "red"
+		} else if entries[a.Symbol] {
+			color = "cyan"
+		}
+		if color != "" {
+			if _, err := fmt.Fprintf(w, fmtDotNode, a.Symbol, color); err != nil {
+				return err
+			}
+		}
+		for _, next := range report.graph.succ[a.id] {
+			if depth, ok := report.depth[next]; ok && depth == a.Depth-1 {
+				lines = append(lines, fmt.Sprintf(fmtDot[c.PrintSubsys], a.Symbol, report.graph.functions[next].Symbol))
+			}
+		}
+	}
+	sort.Strings(lines)
+	for i, l := range lines {
+		if i > 0 && l == lines[i-1] {
+			continue
+		}
+		if _, err := io.WriteString(w, l); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+func writeImpactReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	f, err := os.Open(filepath.Clean(conf.Patch))
+	if err != nil {
+		return fmt.Errorf("problem while opening the patch: %w", err)
+	}
+	defer f.Close()
+	changes, err := parseUnifiedDiff(f)
+	if err != nil {
+		return fmt.Errorf("problem while parsing the patch: %w", err)
+	}
+	report, err := analyzeImpact(d, conf.DBInstance, changes, conf.MaxDepth, conf.EntryPoints)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(report)
+	case "dot":
+		return writeImpactDot(w, report)
+	default:
+		return writeImpactText(w, report)
+	}
+}
@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Impact Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "__x64_sys_read", "fs/read.c", "ksys_read", "fs/read.c", "/build/fs/read.c:5", "0x5"},
+				{2, 3, "ksys_read", "fs/read.c", "vfs_read", "fs/read.c", "/build/fs/read.c:20", "0x20"},
+				{3, 4, "vfs_read", "fs/read.c", "rw_verify", "fs/read.c", "/build/fs/read.c:40", "0x40"},
+				{3, 5, "vfs_read", "fs/read.c", "fsnotify", "fs/notify.c", "/build/fs/read.c:48", "0x48"},
+				{6, 3, "kernel_read", "fs/read.c", "vfs_read", "fs/read.c", "/build/fs/read.c:70", "0x70"},
+				{7, 6, "load_module", "kernel/module.c", "kernel_read", "fs/read.c", "/build/kernel/module.c:9", "0x90"},
+			},
+			subs:    map[string][]string{"fs/read.c": {"VFS"}, "kernel/module.c": {"MODULES"}},
+			markers: []string{"__ksymtab_kernel_read"},
+		}
+	})
+
+	It("Should map the changed lines to the functions around them", func() {
+		ranges := []lineRange{{1, 10, 20}, {2, 30, 40}}
+
+		owners, unmapped := mapLines(ranges, []int{15, 25, 50, 5, 30})
+
+		Expect(owners).To(Equal(map[int][]int{1: {15}, 2: {30}}))
+		Expect(unmapped).To(Equal([]int{25, 50, 5}))
+	})
+
+	It("Should only blame the functions defined in the changed file", func() {
+		src.list = append(src.list, call{8, 5, "fs_inline", "include/linux/fs.h", "fsnotify", "fs/notify.c", "/build/fs/read.c:46", "0x46"})
+		changes := []fileChange{{"fs/read.c", []int{45, 60}}}
+
+		report, err := analyzeImpact(src, 1, changes, 1, nil)
+
+		Expect(err).To(BeNil())
+		Expect(report.Modified).To(Equal([]*changedFunction{{"vfs_read", "fs/read.c", []int{45}}}))
+		Expect(report.Unmapped).To(Equal([]fileChange{{"fs/read.c", []int{60}}}))
+	})
+
+	It("Should report the callers of the changed functions", func() {
+		changes := []fileChange{{"fs/read.c", []int{45}}, {"README", []int{1}}}
+
+		report, err := analyzeImpact(src, 1, changes, 2, nil)
+
+		Expect(err).To(BeNil())
+		Expect(report.Modified).To(Equal([]*changedFunction{{"vfs_read", "fs/read.c", []int{45}}}))
+		Expect(report.Unmapped).To(Equal([]fileChange{{"README", []int{1}}}))
+		Expect(report.Affected).To(Equal([]*impactedFunction{
+			{"vfs_read", "fs/read.c", 0, []string{"VFS"}, 3},
+			{"kernel_read", "fs/read.c", 1, []string{"VFS"}, 6},
+			{"ksys_read", "fs/read.c", 1, []string{"VFS"}, 2},
+			{"__x64_sys_read", "fs/read.c", 2, []string{"VFS"}, 1},
+			{"load_module", "kernel/module.c", 2, []string{"MODULES"}, 7},
+		}))
+		Expect(report.Subsystems).To(Equal([]string{"MODULES", "VFS"}))
+		Expect(report.EntryPoints).To(Equal([]string{"__x64_sys_read", "kernel_read"}))
+	})
+
+	It("Should only use the entry point patterns when the markers are missing", func() {
+		src.missing = fmt.Errorf("nm_symbol: %w", errMissingTable)
+		changes := []fileChange{{"fs/read.c", []int{45}}}
+
+		report, err := analyzeImpact(src, 1, changes, 2, nil)
+
+		Expect(err).To(BeNil())
+		Expect(report.Affected).To(HaveLen(5))
+		Expect(report.EntryPoints).To(Equal([]string{"__x64_sys_read"}))
+	})
+
+	It("Should read the patch and write the graph", func() {
+		dir, err := os.MkdirTemp("", "nav-impact")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+		patch := filepath.Join(dir, "fix.patch")
+		Expect(os.WriteFile(patch, []byte("--- a/fs/read.c\n+++ b/fs/read.c\n@@ -20 +20 @@\n-\tvfs_read();\n+\tvfs_read2();\n"), 0644)).To(Succeed())
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "impact", ReportFormat: "dot", Patch: patch}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("digraph G {\nrankdir=LR; node [style=filled fillcolor=yellow]\n" +
+			"\"ksys_read\" [style=filled; fillcolor=red];\n" +
+			"\"__x64_sys_read\" [style=filled; fillcolor=cyan];\n" +
+			"\"__x64_sys_read\"->\"ksys_read\"; \n" +
+			"}\n"))
+	})
+})
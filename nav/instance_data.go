@@ -0,0 +1,205 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Call site of a whole instance.
+type call struct {
+	callerId   int
+	calleeId   int
+	caller     string
+	callerFile string
+	callee     string
+	calleeFile string
+	sourceRef  string
+	addressRef string
+}
+
+// Function of a whole instance.
+type function struct {
+	id     int
+	symbol string
+	file   string
+	// indirect functions are called through pointers.
+	indirect bool
+}
+
+// Access to the data of a whole instance, used by the reports.
+type instanceSource interface {
+	calls(instance int, fn func(call) error) error
+	functions(instance int, fn func(function) error) error
+	fileSubsystems(instance int) (map[string][]string, error)
+	dataReferenced(instance int) (map[string]bool, error)
+	markerSymbols(instance int) ([]string, error)
+}
+
+// Calls fn for every call site of an instance, sorted by caller, callee and
+// address. The rows are not held in memory.
+func (d *SqlDB) calls(instance int, fn func(call) error) error {
+	query := "select x.caller, x.callee, s1.symbol_name, f1.file_name, s2.symbol_name, f2.file_name, x.source_line, x.ref_addr " +
+		"from xrefs x join symbols s1 on s1.symbol_id=x.caller join files f1 on f1.file_id=s1.symbol_file_ref_id " +
+		"join symbols s2 on s2.symbol_id=x.callee join files f2 on f2.file_id=s2.symbol_file_ref_id " +
+		"where x.xref_instance_id_ref=%[1]d order by x.caller, x.callee, x.ref_addr"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("calls: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cl call
+
+		if err := rows.Scan(&cl.callerId, &cl.calleeId, &cl.caller, &cl.callerFile, &cl.callee, &cl.calleeFile, &cl.sourceRef, &cl.addressRef); err != nil {
+			return fmt.Errorf("calls: %w", err)
+		}
+		if err := fn(cl); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("calls: %w", err)
+	}
+	return nil
+}
+
+// Calls fn for every function of an instance, sorted by id.
+func (d *SqlDB) functions(instance int, fn func(function) error) error {
+	query := "select s.symbol_id, s.symbol_name, f.file_name, s.symbol_type from symbols s " +
+		"join files f on f.file_id=s.symbol_file_ref_id where s.symbol_instance_id_ref=%[1]d order by s.symbol_id"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return fmt.Errorf("functions: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var f function
+		var ty string
+
+		if err := rows.Scan(&f.id, &f.symbol, &f.file, &ty); err != nil {
+			return fmt.Errorf("functions: %w", err)
+		}
+		f.indirect = ty == "indirect"
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("functions: %w", err)
+	}
+	return nil
+}
+
+// Returns the names of the symbols of an instance whose address is taken
+// by some function. The data_xrefs and nm_symbol tables are required.
+func (d *SqlDB) dataReferenced(instance int) (map[string]bool, error) {
+	res := map[string]bool{}
+
+	if err := d.requireTables("data_xrefs", "nm_symbol"); err != nil {
+		return nil, fmt.Errorf("dataReferenced: %w", err)
+	}
+	query := "select distinct n.symbol_name from data_xrefs x join nm_symbol n on n.nm_sym_id=x.data_sym_id " +
+		"where n.nm_symbol_instance_id_ref=%[1]d"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("dataReferenced: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("dataReferenced: %w", err)
+		}
+		res[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("dataReferenced: %w", err)
+	}
+	return res, nil
+}
+
+// Returns the symbols the kernel build adds for the exported functions and
+// the initcalls of an instance. The nm_symbol table is required.
+func (d *SqlDB) markerSymbols(instance int) ([]string, error) {
+	var res []string
+
+	if err := d.requireTables("nm_symbol"); err != nil {
+		return nil, fmt.Errorf("markerSymbols: %w", err)
+	}
+	query := "select symbol_name from nm_symbol where nm_symbol_instance_id_ref=%[1]d and " +
+		"(symbol_name like '__ksymtab_%%' or symbol_name like '__initcall_%%') order by symbol_name"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("markerSymbols: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("markerSymbols: %w", err)
+		}
+		res = append(res, name)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("markerSymbols: %w", err)
+	}
+	return res, nil
+}
+
+// Returns the sorted list of subsystems of every tagged file of an instance.
+func (d *SqlDB) fileSubsystems(instance int) (map[string][]string, error) {
+	res := map[string][]string{}
+
+	query := "select f.file_name, t.subsys_name from tags t join files f on f.file_id=t.tag_file_ref_id " +
+		"where t.tag_instance_id_ref=%[1]d"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("fileSubsystems: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var file, subsys string
+
+		if err := rows.Scan(&file, &subsys); err != nil {
+			return nil, fmt.Errorf("fileSubsystems: %w", err)
+		}
+		res[file] = append(res[file], subsys)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("fileSubsystems: %w", err)
+	}
+	for _, list := range res {
+		sort.Strings(list)
+	}
+	return res, nil
+}
+
+// Returns the subsystems a file belongs to, SUBSYS_UNDEF if it is not tagged.
+func subsystemsOf(subs map[string][]string, file string) []string {
+	if list, ok := subs[file]; ok {
+		return list
+	}
+	return []string{SUBSYS_UNDEF}
+}
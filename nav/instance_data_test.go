@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// In memory instance used to test the reports.
+type fakeInstance struct {
+	list    []call
+	subs    map[string][]string
+	funcs   []function
+	data    map[string]bool
+	markers []string
+	missing error
+}
+
+func (f *fakeInstance) calls(instance int, fn func(call) error) error {
+	for _, cl := range f.list {
+		if err := fn(cl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeInstance) functions(instance int, fn func(function) error) error {
+	for _, fu := range f.funcs {
+		if err := fn(fu); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeInstance) dataReferenced(instance int) (map[string]bool, error) {
+	if f.missing != nil {
+		return nil, f.missing
+	}
+	return f.data, nil
+}
+
+func (f *fakeInstance) markerSymbols(instance int) ([]string, error) {
+	if f.missing != nil {
+		return nil, f.missing
+	}
+	return f.markers, nil
+}
+
+func (f *fakeInstance) fileSubsystems(instance int) (map[string][]string, error) {
+	return f.subs, nil
+}
+
+var _ = Describe("Instance Data Tests", func() {
+	var db *sql.DB
+	var mock sqlmock.Sqlmock
+	var d *SqlDB
+
+	BeforeEach(func() {
+		db, mock, _ = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		d = &SqlDB{db: db}
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	When("calls", func() {
+		query := "select x.caller, x.callee, s1.symbol_name, f1.file_name, s2.symbol_name, f2.file_name, x.source_line, x.ref_addr " +
+			"from xrefs x join symbols s1 on s1.symbol_id=x.caller join files f1 on f1.file_id=s1.symbol_file_ref_id " +
+			"join symbols s2 on s2.symbol_id=x.callee join files f2 on f2.file_id=s2.symbol_file_ref_id " +
+			"where x.xref_instance_id_ref=1 order by x.caller, x.callee, x.ref_addr"
+		columns := []string{"caller", "callee", "s1", "f1", "s2", "f2", "source_line", "ref_addr"}
+
+		It("Should stream every call site", func() {
+			mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 2, "a", "fs/a.c", "b", "mm/b.c", "fs/a.c:10", "0x10").
+				AddRow(1, 3, "a", "fs/a.c", "c", "fs/c.c", "fs/a.c:12", "0x20"))
+			var got []call
+
+			err := d.calls(1, func(cl call) error {
+				got = append(got, cl)
+				return nil
+			})
+
+			Expect(err).To(BeNil())
+			Expect(got).To(Equal([]call{
+				{1, 2, "a", "fs/a.c", "b", "mm/b.c", "fs/a.c:10", "0x10"},
+				{1, 3, "a", "fs/a.c", "c", "fs/c.c", "fs/a.c:12", "0x20"},
+			}))
+		})
+
+		It("Should stop on the first callback error", func() {
+			mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(columns).
+				AddRow(1, 2, "a", "fs/a.c", "b", "mm/b.c", "fs/a.c:10", "0x10").
+				AddRow(1, 3, "a", "fs/a.c", "c", "fs/c.c", "fs/a.c:12", "0x20"))
+			n := 0
+
+			err := d.calls(1, func(cl call) error {
+				n++
+				return fmt.Errorf("stop")
+			})
+
+			Expect(err).To(MatchError("stop"))
+			Expect(n).To(Equal(1))
+		})
+
+		It("Should wrap the query errors", func() {
+			mock.ExpectQuery(query).WillReturnError(fmt.Errorf("connection lost"))
+
+			Expect(d.calls(1, func(call) error { return nil })).To(MatchError("calls: connection lost"))
+		})
+	})
+
+	When("fileSubsystems", func() {
+		It("Should collect the sorted subsystems of each file", func() {
+			mock.ExpectQuery("select f.file_name, t.subsys_name from tags t join files f on f.file_id=t.tag_file_ref_id where t.tag_instance_id_ref=1").
+				WillReturnRows(sqlmock.NewRows([]string{"file_name", "subsys_name"}).
+					AddRow("fs/a.c", "VFS").
+					AddRow("fs/a.c", "EXT4").
+					AddRow("mm/b.c", "MM"))
+
+			subs, err := d.fileSubsystems(1)
+
+			Expect(err).To(BeNil())
+			Expect(subs).To(Equal(map[string][]string{"fs/a.c": {"EXT4", "VFS"}, "mm/b.c": {"MM"}}))
+			Expect(subsystemsOf(subs, "kernel/x.c")).To(Equal([]string{SUBSYS_UNDEF}))
+		})
+	})
+	When("functions", func() {
+		It("Should stream every function with its type", func() {
+			mock.ExpectQuery("select s.symbol_id, s.symbol_name, f.file_name, s.symbol_type from symbols s " +
+				"join files f on f.file_id=s.symbol_file_ref_id where s.symbol_instance_id_ref=1 order by s.symbol_id").
+				WillReturnRows(sqlmock.NewRows([]string{"symbol_id", "symbol_name", "file_name", "symbol_type"}).
+					AddRow(1, "a", "fs/a.c", "direct").
+					AddRow(2, "b", "fs/b.c", "indirect"))
+			var got []function
+
+			err := d.functions(1, func(f function) error {
+				got = append(got, f)
+				return nil
+			})
+
+			Expect(err).To(BeNil())
+			Expect(got).To(Equal([]function{{1, "a", "fs/a.c", false}, {2, "b", "fs/b.c", true}}))
+		})
+	})
+
+	When("markerSymbols", func() {
+		It("Should return the export and initcall symbols", func() {
+			mock.ExpectQuery("select symbol_name from nm_symbol where nm_symbol_instance_id_ref=1 and " +
+				"(symbol_name like '__ksymtab_%' or symbol_name like '__initcall_%') order by symbol_name").
+				WillReturnRows(sqlmock.NewRows([]string{"symbol_name"}).AddRow("__initcall_foo6").AddRow("__ksymtab_bar"))
+
+			Expect(d.markerSymbols(1)).To(Equal([]string{"__initcall_foo6", "__ksymtab_bar"}))
+		})
+	})
+})
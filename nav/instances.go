@@ -0,0 +1,101 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+)
+
+// Prefix of the instance selectors choosing the newest matching instance.
+const instanceLatest = "latest"
+
+// Database instance and its metadata.
+type instance struct {
+	id      int
+	version string
+	note    string
+}
+
+// Returns all the instances in the database, sorted by id.
+func (d *SqlDB) listInstances() ([]instance, error) {
+	var res []instance
+
+	query := "select instance_id, version_string, note from instances order by instance_id"
+	debugQueryPrintln(query)
+	rows, err := d.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("listInstances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var i instance
+		var version, note sql.NullString
+
+		if err := rows.Scan(&i.id, &version, &note); err != nil {
+			return nil, fmt.Errorf("listInstances: %w", err)
+		}
+		i.version = version.String
+		i.note = note.String
+		res = append(res, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("listInstances: %w", err)
+	}
+	return res, nil
+}
+
+// Returns the id of the instance chosen by selector among list, sorted by id.
+// The selector is either a version string or a note, which must identify a
+// single instance, or "latest", optionally followed by ":" and a regular
+// expression, which chooses the newest instance whose version or note
+// matches.
+func resolveInstance(list []instance, selector string) (int, error) {
+	if selector == instanceLatest || strings.HasPrefix(selector, instanceLatest+":") {
+		re, err := regexp.Compile(strings.TrimPrefix(strings.TrimPrefix(selector, instanceLatest), ":"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid instance selector %s: %w", selector, err)
+		}
+		for i := len(list) - 1; i >= 0; i-- {
+			if re.MatchString(list[i].version) || re.MatchString(list[i].note) {
+				return list[i].id, nil
+			}
+		}
+		return 0, fmt.Errorf("no instance matches %s", selector)
+	}
+
+	var ids []string
+	res := 0
+	for _, i := range list {
+		if i.version == selector || i.note == selector {
+			res = i.id
+			ids = append(ids, fmt.Sprint(i.id))
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return 0, fmt.Errorf("no instance has version or note %s", selector)
+	case 1:
+		return res, nil
+	default:
+		return 0, fmt.Errorf("instance %s is ambiguous, it matches ids %s", selector, strings.Join(ids, ", "))
+	}
+}
+
+// Writes the list of instances as a table.
+func writeInstances(w io.Writer, list []instance) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tVERSION\tNOTE")
+	for _, i := range list {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", i.id, i.version, i.note)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var _ = Describe("Instances Tests", func() {
+	list := []instance{
+		{1, "6.1.55", "fedora"},
+		{2, "6.1.60", "fedora"},
+		{3, "6.6.1", "rhel"},
+	}
+
+	When("resolveInstance", func() {
+		It("Should select an instance by version", func() {
+			Expect(resolveInstance(list, "6.1.60")).To(Equal(2))
+		})
+
+		It("Should select an instance by note", func() {
+			Expect(resolveInstance(list, "rhel")).To(Equal(3))
+		})
+
+		It("Should refuse ambiguous selectors", func() {
+			_, err := resolveInstance(list, "fedora")
+			Expect(err).To(MatchError("instance fedora is ambiguous, it matches ids 1, 2"))
+		})
+
+		It("Should refuse unknown selectors", func() {
+			_, err := resolveInstance(list, "5.10")
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("Should select the newest instance", func() {
+			Expect(resolveInstance(list, "latest")).To(Equal(3))
+		})
+
+		It("Should select the newest matching instance", func() {
+			Expect(resolveInstance(list, "latest:^6\\.1\\.")).To(Equal(2))
+			Expect(resolveInstance(list, "latest:fedora")).To(Equal(2))
+		})
+
+		It("Should refuse invalid expressions", func() {
+			_, err := resolveInstance(list, "latest:(")
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
+	When("listInstances", func() {
+		It("Should return the instances with their metadata", func() {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			rows := sqlmock.NewRows([]string{"instance_id", "version_string", "note"}).
+				AddRow(1, "6.1.55", "fedora").
+				AddRow(2, "6.6.1", nil)
+			mock.ExpectQuery("select instance_id, version_string, note from instances order by instance_id").WillReturnRows(rows)
+			d := &SqlDB{db: db}
+
+			res, err := d.listInstances()
+
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal([]instance{{1, "6.1.55", "fedora"}, {2, "6.6.1", ""}}))
+		})
+	})
+
+	When("writeInstances", func() {
+		It("Should write a table of the instances", func() {
+			var b bytes.Buffer
+
+			Expect(writeInstances(&b, list[:2])).To(Succeed())
+
+			Expect(b.String()).To(Equal("ID  VERSION  NOTE\n1   6.1.55   fedora\n2   6.1.60   fedora\n"))
+		})
+	})
+})
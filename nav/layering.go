@@ -0,0 +1,255 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"nav/config"
+)
+
+// Returned by the checks finding violations, so that the caller can exit
+// with a failure status after the report is written.
+var errViolations = errors.New("violations found")
+
+const (
+	ruleAllow  = "allow"
+	ruleForbid = "forbid"
+)
+
+// Functions a rule applies to: subsystem and file regular expressions, an
+// empty one matches any function.
+type ruleSide struct {
+	Subsys string `json:"subsys"`
+	Path   string `json:"path"`
+	subsys *regexp.Regexp
+	path   *regexp.Regexp
+}
+
+// Layering rule, allowing or forbidding the calls from the caller to the
+// callee functions.
+type layerRule struct {
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Caller ruleSide `json:"caller"`
+	Callee ruleSide `json:"callee"`
+}
+
+type layerRules struct {
+	Rules []*layerRule `json:"rules"`
+}
+
+// Call site breaking a rule.
+type violation struct {
+	Rule             string   `json:"rule"`
+	Caller           string   `json:"caller"`
+	CallerFile       string   `json:"caller_file"`
+	CallerSubsystems []string `json:"caller_subsystems"`
+	Callee           string   `json:"callee"`
+	CalleeFile       string   `json:"callee_file"`
+	CalleeSubsystems []string `json:"callee_subsystems"`
+	SourceLine       string   `json:"source_line"`
+	Address          string   `json:"address"`
+}
+
+func compileRulePattern(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile(expr)
+}
+
+func (s *ruleSide) compile() error {
+	var err error
+
+	if s.subsys, err = compileRulePattern(s.Subsys); err != nil {
+		return err
+	}
+	s.path, err = compileRulePattern(s.Path)
+	return err
+}
+
+func (s *ruleSide) match(file string, subsystems []string) bool {
+	if s.path != nil && !s.path.MatchString(file) {
+		return false
+	}
+	if s.subsys == nil {
+		return true
+	}
+	for _, subsys := range subsystems {
+		if s.subsys.MatchString(subsys) {
+			return true
+		}
+	}
+	return false
+}
+
+// Reads and validates a rules file.
+func loadRules(path string) (*layerRules, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("problem while reading rules file: %w", err)
+	}
+	rules := &layerRules{}
+	if err := json.Unmarshal(data, rules); err != nil {
+		return nil, fmt.Errorf("problem while parsing rules file: %w", err)
+	}
+	for i, r := range rules.Rules {
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if r.Action != ruleAllow && r.Action != ruleForbid {
+			return nil, fmt.Errorf("%s: invalid action %q, choose one of the following: allow or forbid", r.Name, r.Action)
+		}
+		if err := r.Caller.compile(); err != nil {
+			return nil, fmt.Errorf("%s: invalid caller pattern: %w", r.Name, err)
+		}
+		if err := r.Callee.compile(); err != nil {
+			return nil, fmt.Errorf("%s: invalid callee pattern: %w", r.Name, err)
+		}
+	}
+	return rules, nil
+}
+
+// Returns the first rule matching a call, nil if none does.
+func (rs *layerRules) match(cl call, callerSubs, calleeSubs []string) *layerRule {
+	for _, r := range rs.Rules {
+		if r.Caller.match(cl.callerFile, callerSubs) && r.Callee.match(cl.calleeFile, calleeSubs) {
+			return r
+		}
+	}
+	return nil
+}
+
+// Returns the call sites of an instance forbidden by the rules. The first
+// rule matching a call decides, calls matching no rule are allowed.
+func checkLayering(d instanceSource, instance int, rules *layerRules) ([]violation, error) {
+	res := []violation{}
+
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	err = d.calls(instance, func(cl call) error {
+		callerSubs := subsystemsOf(subs, cl.callerFile)
+		calleeSubs := subsystemsOf(subs, cl.calleeFile)
+		if r := rules.match(cl, callerSubs, calleeSubs); r != nil && r.Action == ruleForbid {
+			res = append(res, violation{r.Name, cl.caller, cl.callerFile, callerSubs,
+				cl.callee, cl.calleeFile, calleeSubs, cl.sourceRef, cl.addressRef})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func (v violation) String() string {
+	return fmt.Sprintf("%s: %s [%s] -> %s [%s] at %s", v.SourceLine, v.Caller, strings.Join(v.CallerSubsystems, ", "),
+		v.Callee, strings.Join(v.CalleeSubsystems, ", "), v.Address)
+}
+
+func writeViolationsText(w io.Writer, list []violation) error {
+	for _, v := range list {
+		if _, err := fmt.Fprintf(w, "%s: %s\n", v.Rule, v); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d violations\n", len(list))
+	return err
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitTestSuite struct {
+	XMLName   xml.Name        `xml:"testsuite"`
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// Writes a JUnit test suite with a test case for each forbidding rule,
+// failing with the list of its violations.
+func writeViolationsJUnit(w io.Writer, rules *layerRules, list []violation) error {
+	suite := junitTestSuite{Name: "layering", TestCases: []junitTestCase{}}
+
+	for _, r := range rules.Rules {
+		if r.Action != ruleForbid {
+			continue
+		}
+		tc := junitTestCase{Name: r.Name, ClassName: "layering"}
+		var lines []string
+		for _, v := range list {
+			if v.Rule == r.Name {
+				lines = append(lines, v.String())
+			}
+		}
+		if len(lines) > 0 {
+			tc.Failure = &junitFailure{fmt.Sprintf("%d violations", len(lines)), strings.Join(lines, "\n")}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, tc)
+	}
+	suite.Tests = len(suite.TestCases)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func writeLayeringReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	rules, err := loadRules(conf.RulesFile)
+	if err != nil {
+		return err
+	}
+	list, err := checkLayering(d, conf.DBInstance, rules)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		err = enc.Encode(list)
+	case "junit":
+		err = writeViolationsJUnit(w, rules, list)
+	default:
+		err = writeViolationsText(w, list)
+	}
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		return fmt.Errorf("%d layering %w", len(list), errViolations)
+	}
+	return nil
+}
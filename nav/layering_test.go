@@ -0,0 +1,115 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Layering Tests", func() {
+	var src *fakeInstance
+	var dir string
+
+	writeRules := func(content string) string {
+		path := filepath.Join(dir, "rules.json")
+		Expect(os.WriteFile(path, []byte(content), 0644)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "nav-layering")
+		Expect(err).To(BeNil())
+		src = &fakeInstance{
+			list: []call{
+				{1, 3, "e1000_probe", "drivers/net/e1000.c", "__sched_core", "kernel/sched/core.c", "drivers/net/e1000.c:10", "0x10"},
+				{1, 4, "e1000_probe", "drivers/net/e1000.c", "schedule", "kernel/sched/api.c", "drivers/net/e1000.c:12", "0x14"},
+				{2, 3, "ahci_init", "drivers/ata/ahci.c", "__sched_core", "kernel/sched/core.c", "drivers/ata/ahci.c:5", "0x30"},
+			},
+			subs: map[string][]string{"drivers/net/e1000.c": {"E1000"}, "kernel/sched/core.c": {"SCHEDULER"}, "kernel/sched/api.c": {"SCHEDULER"}},
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("Should let the first matching rule decide", func() {
+		rules, err := loadRules(writeRules(`{"rules": [
+			{"name": "ata may use the core", "action": "allow", "caller": {"path": "^drivers/ata/"}},
+			{"name": "no sched internals", "action": "forbid", "caller": {"path": "^drivers/"}, "callee": {"subsys": "^SCHEDULER$", "path": "core\\.c$"}}
+		]}`))
+		Expect(err).To(BeNil())
+
+		list, err := checkLayering(src, 1, rules)
+
+		Expect(err).To(BeNil())
+		Expect(list).To(Equal([]violation{{"no sched internals", "e1000_probe", "drivers/net/e1000.c", []string{"E1000"},
+			"__sched_core", "kernel/sched/core.c", []string{"SCHEDULER"}, "drivers/net/e1000.c:10", "0x10"}}))
+	})
+
+	It("Should reject invalid rules", func() {
+		_, err := loadRules(writeRules(`{"rules": [{"action": "deny"}]}`))
+		Expect(err).To(MatchError(`rule 1: invalid action "deny", choose one of the following: allow or forbid`))
+
+		_, err = loadRules(writeRules(`{"rules": [{"name": "r", "action": "forbid", "callee": {"subsys": "("}}]}`))
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(HavePrefix("r: invalid callee pattern"))
+	})
+
+	It("Should report the violations and fail", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "layering", ReportFormat: "text",
+			RulesFile: writeRules(`{"rules": [{"name": "no sched internals", "action": "forbid", "callee": {"path": "core\\.c$"}}]}`)}
+
+		err := writeReport(&buf, src, &conf)
+
+		Expect(errors.Is(err, errViolations)).To(BeTrue())
+		Expect(err.Error()).To(Equal("2 layering violations found"))
+		Expect(buf.String()).To(Equal(
+			"no sched internals: drivers/net/e1000.c:10: e1000_probe [E1000] -> __sched_core [SCHEDULER] at 0x10\n" +
+				"no sched internals: drivers/ata/ahci.c:5: ahci_init [The REST] -> __sched_core [SCHEDULER] at 0x30\n" +
+				"2 violations\n"))
+	})
+
+	It("Should write a junit test case for each forbidding rule", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "layering", ReportFormat: "junit",
+			RulesFile: writeRules(`{"rules": [
+				{"name": "no sched internals", "action": "forbid", "caller": {"subsys": "E1000"}, "callee": {"path": "core\\.c$"}},
+				{"name": "no net", "action": "forbid", "callee": {"path": "^net/"}}
+			]}`)}
+
+		err := writeReport(&buf, src, &conf)
+
+		Expect(errors.Is(err, errViolations)).To(BeTrue())
+		Expect(buf.String()).To(Equal(`<?xml version="1.0" encoding="UTF-8"?>
+<testsuite name="layering" tests="2" failures="1">
+  <testcase name="no sched internals" classname="layering">
+    <failure message="1 violations">drivers/net/e1000.c:10: e1000_probe [E1000] -&gt; __sched_core [SCHEDULER] at 0x10</failure>
+  </testcase>
+  <testcase name="no net" classname="layering"></testcase>
+</testsuite>
+`))
+	})
+
+	It("Should succeed without violations", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "layering", ReportFormat: "json",
+			RulesFile: writeRules(`{"rules": [{"action": "forbid", "callee": {"path": "^net/"}}]}`)}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("[]\n"))
+	})
+})
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"fmt"
+	"nav/config"
+	"sort"
+	"strings"
+)
+
+var fmtDotNodeLink = "\"%s\" [%s];\n"
+
+// Builds the hyperlinks and tooltips attached to the graph elements.
+type linker struct {
+	url      string
+	tooltips bool
+}
+
+// Returns the linker for the given configuration, nil if neither links nor
+// tooltips are requested.
+func newLinker(conf *config.ConfValues) *linker {
+	if conf.URLTemplate == "" && !conf.Tooltips {
+		return nil
+	}
+	return &linker{conf.URLTemplate, conf.Tooltips}
+}
+
+// Escapes a string so that it can be used as a dot quoted string.
+func dotEscape(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s)
+}
+
+// Splits a source reference in the "file:line" form.
+func splitSourceRef(ref string) (string, string) {
+	i := strings.LastIndex(ref, ":")
+	if i < 0 {
+		return ref, ""
+	}
+	return ref[:i], ref[i+1:]
+}
+
+// Returns the url template with its placeholders replaced.
+func (l *linker) expand(file, line, symbol, subsys, address string) string {
+	return strings.NewReplacer(
+		"{file}", file,
+		"{line}", line,
+		"{symbol}", symbol,
+		"{subsys}", subsys,
+		"{address}", address,
+	).Replace(l.url)
+}
+
+// Returns the dot attributes to add to a function node.
+// The definition line of a function is not in the database, so {line} is
+// only meaningful on edges, and expands to the empty string on nodes.
+func (l *linker) nodeAttrs(symbol string, info nodeInfo) string {
+	var attrs []string
+
+	if l.url != "" && info.file != "" {
+		attrs = append(attrs, fmt.Sprintf("URL=\"%s\"", dotEscape(l.expand(info.file, "", symbol, info.subsys, info.address))))
+	}
+	if l.tooltips {
+		tip := fmt.Sprintf("%s\\nfile: %s\\nsubsystem: %s\\naddress: %s",
+			dotEscape(symbol), dotEscape(info.file), dotEscape(info.subsys), dotEscape(info.address))
+		attrs = append(attrs, fmt.Sprintf("tooltip=\"%s\"", tip))
+	}
+	return strings.Join(attrs, "; ")
+}
+
+// Returns the dot attributes to add to an edge, prefixed by a separator.
+// The edge points to the first call site found in the caller.
+func (l *linker) edgeAttrs(e *graphEdge) string {
+	var res string
+
+	if l == nil {
+		return ""
+	}
+	file, line := splitSourceRef(e.r.sourceRef)
+	if l.url != "" && file != "" && file != "NONE" {
+		res += fmt.Sprintf("; URL = \"%s\"", dotEscape(l.expand(file, line, e.r.symbol, e.r.subsys, e.r.addressRef)))
+	}
+	if l.tooltips {
+		tip := fmt.Sprintf("%s -> %s\\ncall site: %s\\naddress: %s",
+			dotEscape(e.l.symbol), dotEscape(e.r.symbol), dotEscape(e.r.sourceRef), dotEscape(e.r.addressRef))
+		res += fmt.Sprintf("; tooltip = \"%s\"", tip)
+	}
+	return res
+}
+
+// Returns the dot statements adding links and tooltips to the functions of
+// the output graph, sorted by name.
+func dotLinks(info map[string]nodeInfo, l *linker) string {
+	var res string
+	var symbols []string
+
+	for symbol := range info {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	for _, symbol := range symbols {
+		if attrs := l.nodeAttrs(symbol, info[symbol]); attrs != "" {
+			res += fmt.Sprintf(fmtDotNodeLink, symbol, attrs)
+		}
+	}
+	return res
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"nav/config"
+	c "nav/constants"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Links Tests", func() {
+	l := &linker{url: "file:///src/{file}#L{line}", tooltips: true}
+
+	When("newLinker", func() {
+		It("Should return nil if neither links nor tooltips are requested", func() {
+			Expect(newLinker(&config.ConfValues{})).To(BeNil())
+		})
+	})
+
+	When("splitSourceRef", func() {
+		It("Should split the file from the line", func() {
+			file, line := splitSourceRef("kernel/sched/core.c:42")
+			Expect(file).To(Equal("kernel/sched/core.c"))
+			Expect(line).To(Equal("42"))
+		})
+
+		It("Should keep references without line", func() {
+			file, line := splitSourceRef("NONE")
+			Expect(file).To(Equal("NONE"))
+			Expect(line).To(BeEmpty())
+		})
+	})
+
+	When("edgeAttrs", func() {
+		It("Should link the edge to the call site", func() {
+			e := &graphEdge{l: node{symbol: "a"}, r: node{symbol: "b", sourceRef: "mm/slab.c:7", addressRef: "0x10"}}
+
+			Expect(l.edgeAttrs(e)).To(Equal("; URL = \"file:///src/mm/slab.c#L7\"; tooltip = \"a -> b\\ncall site: mm/slab.c:7\\naddress: 0x10\""))
+		})
+
+		It("Should add nothing without a linker", func() {
+			var none *linker
+			Expect(none.edgeAttrs(&graphEdge{})).To(BeEmpty())
+		})
+	})
+
+	When("nodeAttrs", func() {
+		It("Should show the address of the function", func() {
+			info := nodeInfo{subsys: "mm", file: "mm/b.c", address: "0xffffffff81000010"}
+
+			Expect(l.nodeAttrs("b", info)).To(Equal("URL=\"file:///src/mm/b.c#L\"; tooltip=\"b\\nfile: mm/b.c\\nsubsystem: mm\\naddress: 0xffffffff81000010\""))
+			Expect((&linker{url: "{symbol}@{address}"}).nodeAttrs("b", info)).To(Equal("URL=\"b@0xffffffff81000010\""))
+		})
+	})
+
+	When("dotLinks", func() {
+		It("Should link every function to its source file", func() {
+			res := dotLinks(map[string]nodeInfo{"b": {subsys: "mm", file: "mm/b.c"}, "a": {subsys: "sched", file: "kernel/a.c"}}, &linker{url: "{file}?s={symbol}"})
+
+			Expect(res).To(Equal("\"a\" [URL=\"kernel/a.c?s=a\"];\n\"b\" [URL=\"mm/b.c?s=b\"];\n"))
+		})
+	})
+
+	When("writeDot", func() {
+		It("Should add links to the edges and the functions", func() {
+			var b bytes.Buffer
+			d := &sqlMock{}
+			d.init(nil)
+			d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", symId: 1}, nil)
+			d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+				{symbol: "b", fn: "mm/b.c", symId: 2, sourceRef: "kernel/a.c:3", addressRef: "0x10"},
+			}, nil)
+			conf := config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, URLTemplate: "{file}#L{line}"}
+
+			Expect(writeDot(&b, d, &conf, 1, newNavState())).To(BeNil())
+
+			Expect(b.String()).To(ContainSubstring("\"a\"->\"b\" [ edgeid = \"1\"; URL = \"kernel/a.c#L3\"]; \n"))
+			Expect(b.String()).To(ContainSubstring("\"b\" [URL=\"mm/b.c#L\"];\n"))
+		})
+	})
+})
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"nav/config"
+	c "nav/constants"
+)
+
+// Number of calls between every pair of subsystems of an instance.
+type subsysMatrix struct {
+	Subsystems []string `json:"subsystems"`
+	Calls      [][]int  `json:"calls"`
+}
+
+// Returns the subsystem dependency matrix of an instance. A call between
+// functions belonging to several subsystems is accounted for every pair.
+func buildMatrix(d instanceSource, instance int) (*subsysMatrix, error) {
+	counts := map[string]map[string]int{}
+	names := map[string]bool{}
+
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	err = d.calls(instance, func(cl call) error {
+		for _, l := range subsystemsOf(subs, cl.callerFile) {
+			if counts[l] == nil {
+				counts[l] = map[string]int{}
+			}
+			for _, r := range subsystemsOf(subs, cl.calleeFile) {
+				counts[l][r]++
+				names[l] = true
+				names[r] = true
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m := &subsysMatrix{Subsystems: []string{}, Calls: [][]int{}}
+	for name := range names {
+		m.Subsystems = append(m.Subsystems, name)
+	}
+	sort.Strings(m.Subsystems)
+	for _, l := range m.Subsystems {
+		row := make([]int, len(m.Subsystems))
+		for j, r := range m.Subsystems {
+			row[j] = counts[l][r]
+		}
+		m.Calls = append(m.Calls, row)
+	}
+	return m, nil
+}
+
+// Writes the matrix as comma separated values, a row for each caller
+// subsystem and a column for each callee subsystem.
+func (m *subsysMatrix) writeCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append([]string{"caller\\callee"}, m.Subsystems...)); err != nil {
+		return err
+	}
+	for i, l := range m.Subsystems {
+		row := []string{l}
+		for _, n := range m.Calls[i] {
+			row = append(row, strconv.Itoa(n))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (m *subsysMatrix) writeJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(m)
+}
+
+// Writes the matrix as a dot graph, whose edges are weighted by the number
+// of calls. Calls within a subsystem are not shown.
+func (m *subsysMatrix) writeDot(w io.Writer) error {
+	if _, err := io.WriteString(w, "digraph G {\nrankdir=LR; node [style=filled fillcolor=yellow]\n"); err != nil {
+		return err
+	}
+	for i, l := range m.Subsystems {
+		for j, r := range m.Subsystems {
+			if i == j || m.Calls[i][j] == 0 {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, fmtDotWeighted[c.PrintSubsys], l, r, penWidth(m.Calls[i][j]), strconv.Itoa(m.Calls[i][j])); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+func writeMatrixReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	m, err := buildMatrix(d, conf.DBInstance)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		return m.writeJSON(w)
+	case "dot":
+		return m.writeDot(w)
+	default:
+		return m.writeCSV(w)
+	}
+}
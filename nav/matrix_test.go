@@ -0,0 +1,67 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Matrix Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "a", "fs/a.c", "b", "mm/b.c", "fs/a.c:10", "0x10"},
+				{1, 2, "a", "fs/a.c", "b", "mm/b.c", "fs/a.c:11", "0x14"},
+				{2, 3, "b", "mm/b.c", "c", "mm/c.c", "mm/b.c:5", "0x30"},
+				{2, 4, "b", "mm/b.c", "d", "lib/d.c", "mm/b.c:6", "0x34"},
+			},
+			subs: map[string][]string{"fs/a.c": {"EXT4", "VFS"}, "mm/b.c": {"MM"}, "mm/c.c": {"MM"}},
+		}
+	})
+
+	It("Should count the calls between every pair of subsystems", func() {
+		m, err := buildMatrix(src, 1)
+
+		Expect(err).To(BeNil())
+		Expect(m.Subsystems).To(Equal([]string{"EXT4", "MM", SUBSYS_UNDEF, "VFS"}))
+		Expect(m.Calls).To(Equal([][]int{
+			{0, 2, 0, 0},
+			{0, 1, 1, 0},
+			{0, 0, 0, 0},
+			{0, 2, 0, 0},
+		}))
+	})
+
+	It("Should write the matrix in every format", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "matrix"}
+
+		conf.ReportFormat = "csv"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("caller\\callee,EXT4,MM,The REST,VFS\n" +
+			"EXT4,0,2,0,0\nMM,0,1,1,0\nThe REST,0,0,0,0\nVFS,0,2,0,0\n"))
+
+		buf.Reset()
+		conf.ReportFormat = "json"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal(`{"subsystems":["EXT4","MM","The REST","VFS"],"calls":[[0,2,0,0],[0,1,1,0],[0,0,0,0],[0,2,0,0]]}` + "\n"))
+
+		buf.Reset()
+		conf.ReportFormat = "dot"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(HavePrefix("digraph G {"))
+		Expect(buf.String()).To(ContainSubstring(`"EXT4"->"MM" [ penwidth = `))
+		Expect(buf.String()).To(ContainSubstring(`label = "2"]`))
+		Expect(buf.String()).ToNot(ContainSubstring(`"MM"->"MM"`))
+	})
+})
@@ -6,30 +6,44 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"encoding/base64"
-	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/goccy/go-graphviz"
+	"io"
+	"math"
 	"nav/config"
 	c "nav/constants"
-	"github.com/goccy/go-graphviz"
 	"os"
+	"strconv"
 	"strings"
 )
 
-const jsonOutputFMT string = "{\"graph\": \"%s\",\"graph_type\":\"%s\",\"symbols\": [edge%s]}"
+const jsonOutputHead string = "{\"graph\": \""
+const jsonOutputTailFMT string = "\",\"graph_type\":\"%s\",\"symbols\": [%s],\"edges\": %s,\"truncated\": %t}"
 
 var fmtDot = []string{
 	"",
-	"\"%s\"->\"%s\" [ edgeid = \"%d\"]; \n",
+	"\"%s\"->\"%s\" [ edgeid = \"%d\"%s]; \n",
 	"\"%s\"->\"%s\"; \n",
 	"\"%s\"->\"%s\"; \n",
 	"\"%s\"->\"%s\" \n",
 	"",
 }
 
+var fmtDotWeighted = []string{
+	"",
+	"\"%s\"->\"%s\" [ edgeid = \"%d\"; penwidth = %.2f; label = \"%s\"%s]; \n",
+	"\"%s\"->\"%s\" [ penwidth = %.2f; label = \"%s\"]; \n",
+	"\"%s\"->\"%s\" [ penwidth = %.2f; xlabel = \"%s\"]\n",
+	"\"%s\"->\"%s\" [ penwidth = %.2f; xlabel = \"%s\"]\n",
+	"",
+}
+
 var fmtDotHeader = []string{
 	"",
 	"digraph G {\nrankdir=LR; node [style=filled fillcolor=yellow]\n",
@@ -40,6 +54,10 @@ var fmtDotHeader = []string{
 	"digraph G {\nlayout=\"fdp\"\noverlap=\"1:scalexy\"\nnode [shape=\"box\";style=filled;color=green];\n",
 }
 
+var fmtDotNode = "\"%s\" [style=filled; fillcolor=%s];\n"
+
+var fmtDotTruncated = "labelloc=\"t\"; label=\"partial graph: %s\";\n"
+
 var fmtDotNodeHighlightWSymb = "\"%[1]s\" [shape=record style=\"rounded,filled,bold\" fillcolor=yellow label=\"%[1]s|%[2]s\"]\n"
 var fmtDotNodeHighlightWoSymb = "\"%[1]s\" [shape=record style=\"rounded,filled,bold\" fillcolor=yellow label=\"%[1]s\"]\n"
 
@@ -49,6 +67,12 @@ func opt2num(s string) int {
 		"jsonOutputPlain": c.JsonOutputPlain,
 		"jsonOutputB64":   c.JsonOutputB64,
 		"jsonOutputGZB64": c.JsonOutputGZB64,
+		"jsonLines":       c.JsonLines,
+		"cflow":           c.Cflow,
+		"csvEdges":        c.CsvEdges,
+		"tsvEdges":        c.TsvEdges,
+		"csvNodes":        c.CsvNodes,
+		"tsvNodes":        c.TsvNodes,
 	}
 	val, ok := opt[s]
 	if !ok {
@@ -57,6 +81,98 @@ func opt2num(s string) int {
 	return val
 }
 
+// Edge representation in the json output.
+type jsonEdge struct {
+	Caller      string     `json:"caller"`
+	Callee      string     `json:"callee"`
+	CallSites   int        `json:"call_sites"`
+	SymbolPairs int        `json:"symbol_pairs"`
+	Snippets    []*snippet `json:"snippets,omitempty"`
+}
+
+// Returns the line width used to represent an edge of the given weight.
+func penWidth(weight int) float64 {
+	if weight < 1 {
+		return 1
+	}
+	return 1 + math.Log2(float64(weight))
+}
+
+// Returns the dot representation of the edges collected during navigation.
+func dotEdges(edges []*graphEdge, mode c.OutMode, weighted bool, links *linker) string {
+	var res string
+
+	for _, e := range edges {
+		switch mode {
+		case c.PrintAll:
+			if weighted {
+				res += fmt.Sprintf(fmtDotWeighted[mode], e.l.symbol, e.r.symbol, e.id, penWidth(e.callSites), strconv.Itoa(e.callSites), links.edgeAttrs(e))
+			} else {
+				res += fmt.Sprintf(fmtDot[mode], e.l.symbol, e.r.symbol, e.id, links.edgeAttrs(e))
+			}
+		default:
+			if weighted {
+				label := fmt.Sprintf("%d calls\\n%d pairs", e.callSites, e.symPairs())
+				res += fmt.Sprintf(fmtDotWeighted[mode], e.l.subsys, e.r.subsys, penWidth(e.callSites), label)
+			} else {
+				res += fmt.Sprintf(fmtDot[mode], e.l.subsys, e.r.subsys)
+			}
+		}
+	}
+	return res
+}
+
+// Returns the available snippets of the call sites of an edge, one per
+// source line.
+func edgeSnippets(e *graphEdge, src *snippetReader) []*snippet {
+	var res []*snippet
+
+	seen := map[string]bool{}
+	for _, s := range e.sites {
+		if seen[s.sourceRef] {
+			continue
+		}
+		seen[s.sourceRef] = true
+		if sn := src.get(s.sourceRef); sn != nil {
+			res = append(res, sn)
+		}
+	}
+	return res
+}
+
+// Returns the json representation of an edge.
+// If src is not nil, the source of each call site is attached.
+func toJsonEdge(e *graphEdge, mode c.OutMode, src *snippetReader) jsonEdge {
+	if mode == c.PrintAll {
+		return jsonEdge{Caller: e.l.symbol, Callee: e.r.symbol, CallSites: e.callSites, SymbolPairs: e.symPairs(), Snippets: edgeSnippets(e, src)}
+	}
+	return jsonEdge{Caller: e.l.subsys, Callee: e.r.subsys, CallSites: e.callSites, SymbolPairs: e.symPairs(), Snippets: edgeSnippets(e, src)}
+}
+
+// Returns the json array describing the edges and their weights.
+func jsonEdges(edges []*graphEdge, mode c.OutMode, src *snippetReader) (string, error) {
+	res := []jsonEdge{}
+
+	for _, e := range edges {
+		res = append(res, toJsonEdge(e, mode, src))
+	}
+	out, err := json.Marshal(res)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Returns the dot statement highlighting a node whose successors are not
+// part of the graph.
+func dotNode(n graphNode) string {
+	color := "red"
+	if n.status == nodeExcluded {
+		color = "orange"
+	}
+	return fmt.Sprintf(fmtDotNode, n.symbol, color)
+}
+
 func decorateLine(l string, r string, adjm []adjM) string {
 	var res = " [label=\""
 
@@ -72,167 +188,334 @@ func decorateLine(l string, r string, adjm []adjM) string {
 	return res
 }
 
+// Adds the call labels to the edges of a subsystems graph. Only the
+// "l"->"r" prefix of each edge is parsed, so that the attributes of
+// weighted edges do not end up in the callee name, and the statement
+// terminator is dropped so that the label stays part of the edge.
 func decorate(dotStr string, adjm []adjM) string {
 	var res string
 
 	dotBody := strings.Split(dotStr, "\n")
-	for i, line := range dotBody {
-		split := strings.Split(line, "->")
+	for _, line := range dotBody {
+		split := strings.Split(line, "\"->\"")
 		if len(split) == 2 {
-			res = res + dotBody[i] + decorateLine(strings.TrimSpace(strings.ReplaceAll(split[0], "\"", "")), strings.TrimSpace(strings.ReplaceAll(split[1], "\"", "")), adjm) + "\n"
+			callee, _, _ := strings.Cut(split[1], "\"")
+			res = res + strings.TrimRight(line, "; ") + decorateLine(strings.TrimSpace(strings.ReplaceAll(split[0], "\"", "")), strings.TrimSpace(callee), adjm) + "\n"
 		}
 	}
 	return res
 }
 
-func do_graphviz(dot string, output_type c.OutIMode) error{
-	var buf bytes.Buffer
+// Renders the dot graph to w in the configured format.
+func do_graphviz(w io.Writer, dot string, conf *config.ConfValues) (err error) {
 	var format graphviz.Format
 
-	switch output_type {
+	switch conf.Graphviz {
 	case c.OPNG:
-		format=graphviz.PNG
+		format = graphviz.PNG
 	case c.OJPG:
-		format=graphviz.JPG
+		format = graphviz.JPG
 	case c.OSVG:
-		format=graphviz.SVG
+		format = graphviz.SVG
+	case c.OPDF:
+		// Rendered as an image and wrapped in a PDF document.
 	default:
 		return errors.New("Unknown format")
 	}
 
-	graph, _ := graphviz.ParseBytes([]byte(dot))
+	graph, err := graphviz.ParseBytes([]byte(dot))
+	if err != nil {
+		return fmt.Errorf("invalid graph: %w", err)
+	}
 	g := graphviz.New()
 	defer func() {
-		if err := graph.Close(); err != nil {
-			panic(err)
+		if closeErr := graph.Close(); err == nil {
+			err = closeErr
+		}
+		if closeErr := g.Close(); err == nil {
+			err = closeErr
 		}
-		g.Close()
 	}()
-	g.Render(graph, format, &buf)
-	binary.Write(os.Stdout, binary.LittleEndian, buf.Bytes())
+
+	if conf.Layout != "" {
+		g.SetLayout(graphviz.Layout(conf.Layout))
+	}
+	if conf.Size != "" {
+		graph.SafeSet("size", conf.Size, "")
+	}
+	if conf.DPI > 0 {
+		graph.SetDPI(conf.DPI)
+	}
+
+	if conf.Graphviz == c.OPDF {
+		img, err := g.RenderImage(graph)
+		if err != nil {
+			return fmt.Errorf("rendering failed: %w", err)
+		}
+		return writePDF(w, img, conf.DPI)
+	}
+	if err := g.Render(graph, format, w); err != nil {
+		return fmt.Errorf("rendering failed: %w", err)
+	}
 	return nil
 }
 
+// Returns the whole output as a string.
 func generateOutput(d Datasource, cfg *config.Config) (string, error) {
-	var graphOutput string
-	var jsonOutput string
-	var prod = map[string]int{}
-	var visited []int
-	var entryName string
-	var output string
-	var adjm []adjM
-
-	archnum := 0
+	var b strings.Builder
+
+	if err := writeOutput(&b, d, cfg); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// Writes the output to w, while it is being computed.
+func writeOutput(w io.Writer, d Datasource, cfg *config.Config) error {
+	st := newNavState()
 	conf := cfg.ConfValues
+	src := newSnippetReader(&conf)
+	st.retain = conf.HTMLReport != ""
 
 	start, err := d.sym2num(conf.Symbol, conf.DBInstance)
 	if err != nil {
 		fmt.Println("Symbol not found")
-		return "", err
+		return err
+	}
+
+	switch opt2num(conf.Type) {
+	case c.GraphOnly:
+		err = writeDot(w, d, &conf, start, st)
+	case c.JsonLines:
+		err = writeJsonLines(w, d, &conf, start, st, src)
+	case c.Cflow:
+		err = writeCflow(w, d, &conf, start, st)
+	case c.CsvEdges, c.TsvEdges, c.CsvNodes, c.TsvNodes:
+		err = writeTable(w, d, &conf, start, st)
+	default:
+		err = writeJson(w, d, &conf, start, st, src)
+	}
+	if err != nil {
+		return err
+	}
+	if conf.HTMLReport != "" {
+		if err := saveHTMLReport(conf.HTMLReport, &conf, st, src); err != nil {
+			return fmt.Errorf("html report: %w", err)
+		}
+	}
+	return nil
+}
+
+// Writes the json output, the dot graph is embedded as it is computed.
+func writeJson(w io.Writer, d Datasource, conf *config.ConfValues, start int, st *navState, src *snippetReader) error {
+	var gw io.WriteCloser
+
+	switch opt2num(conf.Type) {
+	case c.JsonOutputPlain:
+		gw = jsonStringWriter{w}
+	case c.JsonOutputB64:
+		gw = base64.NewEncoder(base64.StdEncoding, w)
+	case c.JsonOutputGZB64:
+		gw = newGzipB64Writer(w)
+	default:
+		return errors.New("unknown output mode")
+	}
+
+	if _, err := io.WriteString(w, jsonOutputHead); err != nil {
+		return err
+	}
+	// the symbols and edges are written after the graph.
+	st.retain = true
+	if err := writeDot(gw, d, conf, start, st); err != nil {
+		return err
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("graph encoding failed: %w", err)
+	}
+	symbdata, err := d.symbSubsys(st.order, conf.DBInstance)
+	if err != nil {
+		return err
+	}
+	edgeData, err := jsonEdges(st.edges, conf.Mode, src)
+	if err != nil {
+		return err
 	}
+	_, err = fmt.Fprintf(w, jsonOutputTailFMT, conf.Type, symbdata, edgeData, st.truncated != "")
+	return err
+}
 
-	graphOutput = fmtDotHeader[conf.Mode]
+// Writes the dot graph to w. In PrintAll mode, edges are written as soon as
+// they are found.
+func writeDot(w io.Writer, d Datasource, conf *config.ConfValues, start int, st *navState) error {
+	if _, err := io.WriteString(w, fmtDotHeader[conf.Mode]); err != nil {
+		return err
+	}
 	if conf.Mode <= c.PrintTargeted {
-		entry, err := d.getEntryById(start, conf.DBInstance)
+		ann, err := newAnnotations(conf)
 		if err != nil {
-			return "", err
-		} else {
-			entryName = entry.symbol
+			return err
 		}
-		startSubsys, _ := d.getSubsysFromSymbolName(entryName, conf.DBInstance)
-		if startSubsys == "" {
-			startSubsys = SUBSYS_UNDEF
+		if conf.Mode == c.PrintAll {
+			st.sink = &dotSink{w, conf.Mode, conf.EdgeWeights, newLinker(conf)}
 		}
-
-		if (conf.Mode == c.PrintTargeted) && len(conf.TargetSubsys) == 0 {
-			targSubsysTmp, err := d.getSubsysFromSymbolName(conf.Symbol, conf.DBInstance)
-			if err != nil {
-				panic(err)
-			}
-			conf.TargetSubsys = append(conf.TargetSubsys, targSubsysTmp)
+		if err := explore(d, start, conf, st); err != nil {
+			return err
 		}
 
-		navigate(d, start, node{startSubsys, entryName, "entry point", "0x0"}, conf.TargetSubsys, &visited, &adjm, prod, conf.DBInstance, conf.Mode, conf.ExcludedAfter, conf.ExcludedBefore, 0, conf.MaxDepth, fmtDot[conf.Mode], &output, &archnum)
-
+		output := ""
+		if conf.Mode != c.PrintAll {
+			output = dotEdges(st.edges, conf.Mode, conf.EdgeWeights, nil)
+		}
 		if (conf.Mode == c.PrintSubsysWs) || (conf.Mode == c.PrintTargeted) {
-			output = decorate(output, adjm)
+			output = decorate(output, st.adjMap)
+		}
+		for _, n := range st.nodes {
+			output += dotNode(n)
+		}
+		if conf.ClusterBy != "" {
+			output += dotClusters(st.info, conf.ClusterBy)
+		}
+		if links := newLinker(conf); links != nil {
+			output += dotLinks(st.info, links)
+		}
+		if ann != nil {
+			output += dotAnnotations(st.info, ann, conf.Mode, st.edges)
+		}
+		if st.truncated != "" {
+			output += fmt.Sprintf(fmtDotTruncated, st.truncated)
 		}
-
-		graphOutput += output
 		if conf.Mode == c.PrintTargeted {
 			for _, i := range conf.TargetSubsys {
 				if d.GetExploredSubsystemByName(conf.Symbol) == i {
-					graphOutput += fmt.Sprintf(fmtDotNodeHighlightWSymb, i, conf.Symbol)
+					output += fmt.Sprintf(fmtDotNodeHighlightWSymb, i, conf.Symbol)
 				} else {
-					graphOutput += fmt.Sprintf(fmtDotNodeHighlightWoSymb, i)
+					output += fmt.Sprintf(fmtDotNodeHighlightWoSymb, i)
 				}
 			}
 		}
+		if _, err := io.WriteString(w, output); err != nil {
+			return err
+		}
 	} else {
-/*
-		print " ##symb## [shape=house;style=filled;color=cyan;];"
-		set = select * from nm_symbol where nm_sym_id in (select data_sym_id from data_xrefs where func_id in (select symbol_id from symbols where symbol_name ='##symb##' and symbol_instance_id_ref=##i##));
-		for x in set {
-		        print " "##x##" [shape=box;style=filled;color=green]; "
-		}
-		for x in set {
-		        arch = select subsys_name, '##x##'  from tags where tag_file_ref_id in (select file_id from files where file_id in (select symbol_file_ref_id from symbols where symbol_id in (select func_id from data_xrefs where data_sym_id in (s>
-		        for y in arch {
-		                print " "##y1##" -> "##y2##"
-		        }
-		}
-*/
-		graphOutput += fmt.Sprintf(" \"%s\" [shape=house;style=filled;color=cyan;width=5, height=2, fixedsize=true];\n", conf.Symbol)
+		/*
+			print " ##symb## [shape=house;style=filled;color=cyan;];"
+			set = select * from nm_symbol where nm_sym_id in (select data_sym_id from data_xrefs where func_id in (select symbol_id from symbols where symbol_name ='##symb##' and symbol_instance_id_ref=##i##));
+			for x in set {
+			        print " "##x##" [shape=box;style=filled;color=green]; "
+			}
+			for x in set {
+			        arch = select subsys_name, '##x##'  from tags where tag_file_ref_id in (select file_id from files where file_id in (select symbol_file_ref_id from symbols where symbol_id in (select func_id from data_xrefs where data_sym_id in (s>
+			        for y in arch {
+			                print " "##y1##" -> "##y2##"
+			        }
+			}
+		*/
+		if _, err := fmt.Fprintf(w, " \"%s\" [shape=house;style=filled;color=cyan;width=5, height=2, fixedsize=true];\n", conf.Symbol); err != nil {
+			return err
+		}
 		gdata, err := d.symbGData(conf.Symbol, conf.DBInstance)
-		if err!= nil {
-			panic(err)
+		if err != nil {
+			return err
 		}
 		for _, i := range gdata {
-			graphOutput += fmt.Sprintf("\"%s\" [shape=\"ellipse\";style=filled;color=orange;width=5, height=2, fixedsize=true];\n", i)
+			if _, err := fmt.Fprintf(w, "\"%s\" [shape=\"ellipse\";style=filled;color=orange;width=5, height=2, fixedsize=true];\n", i); err != nil {
+				return err
+			}
 			tmp := d.symbGDataFuncOf(i, conf.DBInstance)
 			for _, j := range tmp {
-				graphOutput += fmt.Sprintf("%s\n", j)
+				if _, err := fmt.Fprintf(w, "%s\n", j); err != nil {
+					return err
+				}
 			}
 		}
 	}
-	graphOutput += "}"
-	switch opt2num(conf.Type) {
-	case c.GraphOnly:
-		jsonOutput = graphOutput
-	case c.JsonOutputPlain:
-		symbdata, err := d.symbSubsys(visited, conf.DBInstance)
-		if err != nil {
-			return "", err
-		}
-		jsonOutput = fmt.Sprintf(jsonOutputFMT, graphOutput, conf.Type, symbdata)
-	case c.JsonOutputB64:
-		symbdata, err := d.symbSubsys(visited, conf.DBInstance)
-		if err != nil {
-			return "", err
-		}
-		b64dot := base64.StdEncoding.EncodeToString([]byte(graphOutput))
-		jsonOutput = fmt.Sprintf(jsonOutputFMT, b64dot, conf.Type, symbdata)
+	_, err := io.WriteString(w, "}")
+	return err
+}
 
-	case c.JsonOutputGZB64:
-		var b bytes.Buffer
-		symbdata, err := d.symbSubsys(visited, conf.DBInstance)
-		if err != nil {
-			return "", err
-		}
-		gz := gzip.NewWriter(&b)
-		if _, err := gz.Write([]byte(graphOutput)); err != nil {
-			return "", errors.New("gzip failed")
+// Writes the graph as json objects, one per line. In PrintAll mode, edges
+// are written as soon as they are found.
+func writeJsonLines(w io.Writer, d Datasource, conf *config.ConfValues, start int, st *navState, src *snippetReader) error {
+	if conf.Mode > c.PrintTargeted {
+		return fmt.Errorf("%s output is not available in mode %d", conf.Type, conf.Mode)
+	}
+	ann, err := newAnnotations(conf)
+	if err != nil {
+		return err
+	}
+	sink := newJsonLinesSink(w, conf.Mode, src)
+	if conf.Mode == c.PrintAll {
+		st.sink = sink
+	}
+	if err := explore(d, start, conf, st); err != nil {
+		return err
+	}
+	if conf.Mode != c.PrintAll {
+		for _, e := range st.edges {
+			if err := sink.edge(e); err != nil {
+				return err
+			}
 		}
-		if err := gz.Close(); err != nil {
-			return "", errors.New("gzip failed")
+	}
+	if err := sink.annotations(st, ann); err != nil {
+		return err
+	}
+	return sink.summary(conf.Type, st)
+}
+
+// Escapes its input as the content of a JSON string.
+type jsonStringWriter struct {
+	w io.Writer
+}
+
+func (j jsonStringWriter) Write(p []byte) (int, error) {
+	var b bytes.Buffer
+
+	for _, ch := range p {
+		switch {
+		case ch == '"' || ch == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(ch)
+		case ch == '\n':
+			b.WriteString(`\n`)
+		case ch == '\t':
+			b.WriteString(`\t`)
+		case ch < 0x20:
+			fmt.Fprintf(&b, `\u%04x`, ch)
+		default:
+			b.WriteByte(ch)
 		}
-		b64dot := base64.StdEncoding.EncodeToString(b.Bytes())
-		jsonOutput = fmt.Sprintf(jsonOutputFMT, b64dot, conf.Type, symbdata)
+	}
+	if _, err := j.w.Write(b.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
 
-	default:
-		return "", errors.New("unknown output mode")
+func (jsonStringWriter) Close() error {
+	return nil
+}
+
+// Gzip compresses its input and base64 encodes the result.
+type gzipB64Writer struct {
+	gz  *gzip.Writer
+	b64 io.WriteCloser
+}
+
+func newGzipB64Writer(w io.Writer) *gzipB64Writer {
+	b64 := base64.NewEncoder(base64.StdEncoding, w)
+	return &gzipB64Writer{gzip.NewWriter(b64), b64}
+}
+
+func (g *gzipB64Writer) Write(p []byte) (int, error) {
+	return g.gz.Write(p)
+}
+
+func (g *gzipB64Writer) Close() error {
+	if err := g.gz.Close(); err != nil {
+		return err
 	}
-	return jsonOutput, nil
+	return g.b64.Close()
 }
 
 func main() {
@@ -249,20 +532,95 @@ func main() {
 	d := &SqlDB{}
 	err = d.init(&t)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(c.OSExitError)
 	}
 
-	output, err := generateOutput(d, conf)
-	if err != nil {
-		fmt.Println("Internal error", err)
-		os.Exit(-3)
+	if conf.ConfValues.ListInstances || conf.ConfValues.Instance != "" {
+		list, err := d.listInstances()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(c.OSExitError)
+		}
+		if conf.ConfValues.ListInstances {
+			if err := writeInstances(os.Stdout, list); err != nil {
+				os.Exit(c.OSExitError)
+			}
+			os.Exit(c.OSExitSuccess)
+		}
+		if conf.ConfValues.DBInstance, err = resolveInstance(list, conf.ConfValues.Instance); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(c.OSExitError)
+		}
 	}
+	if err = d.checkInstance(conf.ConfValues.DBInstance); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(c.OSExitError)
+	}
+
+	if conf.ConfValues.Report != "" {
+		w := bufio.NewWriter(os.Stdout)
+		err = writeReport(w, d, &conf.ConfValues)
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if errors.Is(err, errViolations) {
+				os.Exit(c.OSExitViolations)
+			}
+			os.Exit(c.OSExitError)
+		}
+		os.Exit(c.OSExitSuccess)
+	}
+
+	var cacheFile, fingerprint string
+	if conf.ConfValues.CacheDir != "" {
+		cacheFile = cachePath(conf.ConfValues.CacheDir, conf.ConfValues.DBDriver, conf.ConfValues.DBDSN, conf.ConfValues.DBInstance)
+		fingerprint, err = d.instanceFingerprint(conf.ConfValues.DBInstance)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "persistent cache disabled:", err)
+			cacheFile = ""
+		} else if err = d.cache.load(cacheFile, fingerprint); err != nil {
+			debugIOPrintln("cache not loaded:", err)
+		}
+	}
+
 	if conf.ConfValues.Graphviz != c.OText {
-		err = do_graphviz(output, conf.ConfValues.Graphviz);
+		// Only the graph can be rendered, json data is dropped.
+		gconf := *conf
+		gconf.ConfValues.Type = c.DefaultOutputType
+		output, err := generateOutput(d, &gconf)
 		if err != nil {
-			fmt.Println(err.Error())
+			fmt.Println("Internal error", err)
+			os.Exit(-3)
+		}
+		w := bufio.NewWriter(os.Stdout)
+		err = do_graphviz(w, output, &conf.ConfValues)
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(-3)
 		}
 	} else {
-		fmt.Println(output)
+		w := bufio.NewWriter(os.Stdout)
+		err = writeOutput(w, d, conf)
+		if err == nil {
+			_, err = w.WriteString("\n")
+		}
+		if flushErr := w.Flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			fmt.Println("Internal error", err)
+			os.Exit(-3)
+		}
+	}
+	if cacheFile != "" {
+		if err = d.cache.save(cacheFile, fingerprint); err != nil {
+			fmt.Fprintln(os.Stderr, "unable to store the query cache:", err)
+		}
 	}
 }
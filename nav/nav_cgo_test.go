@@ -192,18 +192,18 @@ dgraph G {
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and " +
 				"symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=472055 " +
 				"and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"472055", "__x64_sys_getpid", "", "kernel/sys.c"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"472055", "__x64_sys_getpid", "", "kernel/sys.c", "0xffffffff81077560"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__x64_sys_getpid' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__x64_sys_getpid' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: nil,
 		})
@@ -216,26 +216,26 @@ dgraph G {
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=501994 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"501994", "__fentry__", "X86 ARCHITECTURE (32-BIT AND 64-BIT)", "arch/x86/kernel/ftrace_64.S"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"501994", "__fentry__", "X86 ARCHITECTURE (32-BIT AND 64-BIT)", "arch/x86/kernel/ftrace_64.S", "0xffffffff81e01fd0"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=472243 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"472243", "__task_pid_nr_ns", "", "kernel/pid.c"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"472243", "__task_pid_nr_ns", "", "kernel/pid.c", "0xffffffff810824d0"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__task_pid_nr_ns' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__task_pid_nr_ns' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: nil,
 		})
@@ -250,26 +250,26 @@ dgraph G {
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=473674 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"473674", "__rcu_read_lock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"473674", "__rcu_read_lock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h", "0xffffffff810c5f20"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=473716 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"473716", "__rcu_read_unlock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"473716", "__rcu_read_unlock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h", "0xffffffff810c6010"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__rcu_read_lock' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__rcu_read_lock' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: [][]driver.Value{{"direct", "READ-COPY UPDATE (RCU)"}},
 		})
@@ -278,7 +278,7 @@ dgraph G {
 			querySTR: "select (select symbol_type from symbols where symbol_name='__rcu_read_unlock' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__rcu_read_unlock' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: [][]driver.Value{{"direct", "READ-COPY UPDATE (RCU)"}},
 		})
@@ -9,6 +9,7 @@
 package main
 
 import (
+	"bytes"
 	"nav/config"
 	c "nav/constants"
 	"database/sql"
@@ -143,13 +144,100 @@ var _ = Describe("Nav Tests", func() {
 		})
 	})
 
+	Describe("dotEdges", func() {
+		edges := []*graphEdge{
+			{
+				l:         node{subsys: "lsys", symbol: "lsym"},
+				r:         node{subsys: "rsys", symbol: "rsym"},
+				id:        1,
+				callSites: 4,
+				pairs:     map[string]bool{"lsym->rsym": true, "lsym2->rsym": true},
+			},
+		}
+
+		It("Should keep the plain format when weights are not requested", func() {
+			Expect(dotEdges(edges, c.PrintAll, false, nil)).To(Equal("\"lsym\"->\"rsym\" [ edgeid = \"1\"]; \n"))
+			Expect(dotEdges(edges, c.PrintSubsys, false, nil)).To(Equal("\"lsys\"->\"rsys\"; \n"))
+		})
+
+		It("Should add the call sites to symbol edges", func() {
+			Expect(dotEdges(edges, c.PrintAll, true, nil)).To(Equal("\"lsym\"->\"rsym\" [ edgeid = \"1\"; penwidth = 3.00; label = \"4\"]; \n"))
+		})
+
+		It("Should add call sites and symbol pairs to subsystem edges", func() {
+			Expect(dotEdges(edges, c.PrintSubsys, true, nil)).To(Equal("\"lsys\"->\"rsys\" [ penwidth = 3.00; label = \"4 calls\\n2 pairs\"]; \n"))
+		})
+
+		It("Should keep the call labels of weighted edges in mode 3", func() {
+			adjm := []adjM{{l: node{subsys: "lsys", symbol: "lsym"}, r: node{subsys: "rsys", symbol: "rsym", sourceRef: "rsource", addressRef: "raddr"}}}
+			Expect(decorate(dotEdges(edges, c.PrintSubsysWs, true, nil), adjm)).To(Equal(
+				"\"lsys\"->\"rsys\" [ penwidth = 3.00; xlabel = \"4 calls\\n2 pairs\"] [label=\"rsym([raddr]rsource),\\n\"]\n"))
+			Expect(decorate(dotEdges(edges, c.PrintSubsysWs, false, nil), adjm)).To(Equal(
+				"\"lsys\"->\"rsys\" [label=\"rsym([raddr]rsource),\\n\"]\n"))
+		})
+	})
+
+	Describe("jsonEdges", func() {
+		It("Should return an empty array if no edges are given", func() {
+			res, err := jsonEdges([]*graphEdge{}, c.PrintAll, nil)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal("[]"))
+		})
+
+		It("Should report the weights of the edges", func() {
+			edges := []*graphEdge{
+				{
+					l:         node{subsys: "lsys", symbol: "lsym"},
+					r:         node{subsys: "rsys", symbol: "rsym"},
+					callSites: 3,
+					pairs:     map[string]bool{"lsym->rsym": true},
+				},
+			}
+			res, err := jsonEdges(edges, c.PrintSubsys, nil)
+			Expect(err).To(BeNil())
+			Expect(res).To(Equal(`[{"caller":"lsys","callee":"rsys","call_sites":3,"symbol_pairs":1}]`))
+		})
+	})
+
+	Describe("do_graphviz", func() {
+		dot := "digraph G {\n\"a\"->\"b\";\n}"
+
+		It("Should render the graph in the requested format", func() {
+			var b bytes.Buffer
+			err := do_graphviz(&b, dot, &config.ConfValues{Graphviz: c.OSVG, Layout: "neato", Size: "2,2", DPI: 72})
+			Expect(err).To(BeNil())
+			Expect(b.String()).To(ContainSubstring("<svg"))
+		})
+
+		It("Should render the graph as PDF", func() {
+			var b bytes.Buffer
+			err := do_graphviz(&b, dot, &config.ConfValues{Graphviz: c.OPDF})
+			Expect(err).To(BeNil())
+			Expect(b.String()).To(HavePrefix("%PDF-"))
+		})
+
+		It("Should report invalid graphs", func() {
+			var b bytes.Buffer
+			err := do_graphviz(&b, "{\"graph\": \"digraph G {}\"}", &config.ConfValues{Graphviz: c.OSVG})
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("Should report unknown formats", func() {
+			var b bytes.Buffer
+			err := do_graphviz(&b, dot, &config.ConfValues{Graphviz: c.OText})
+			Expect(err).ToNot(BeNil())
+		})
+	})
+
 	Describe("generateOutput using sqlmock", func() {
 		var d *sqlMock
 		expectedDot := `digraph G {
-rankdir="LR"
-"__x64_sys_getpid"->"__task_pid_nr_ns" 
-"__task_pid_nr_ns"->"__rcu_read_lock" 
-"__task_pid_nr_ns"->"__rcu_read_unlock" 
+rankdir=LR; node [style=filled fillcolor=yellow]
+"__x64_sys_getpid"->"__task_pid_nr_ns" [ edgeid = "1"]; 
+"__task_pid_nr_ns"->"__rcu_read_lock" [ edgeid = "2"]; 
+"__rcu_read_lock" [style=filled; fillcolor=orange];
+"__task_pid_nr_ns"->"__rcu_read_unlock" [ edgeid = "3"]; 
+"__rcu_read_unlock" [style=filled; fillcolor=orange];
 }`
 		d = &sqlMock{}
 		d.init(nil)
@@ -212,10 +300,12 @@ rankdir="LR"
 		var mock sqlmock.Sqlmock
 		var dok *SqlDB
 		expectedDot := `digraph G {
-rankdir="LR"
-"__x64_sys_getpid"->"__task_pid_nr_ns" 
-"__task_pid_nr_ns"->"__rcu_read_lock" 
-"__task_pid_nr_ns"->"__rcu_read_unlock" 
+rankdir=LR; node [style=filled fillcolor=yellow]
+"__x64_sys_getpid"->"__task_pid_nr_ns" [ edgeid = "1"]; 
+"__task_pid_nr_ns"->"__rcu_read_lock" [ edgeid = "2"]; 
+"__rcu_read_lock" [style=filled; fillcolor=orange];
+"__task_pid_nr_ns"->"__rcu_read_unlock" [ edgeid = "3"]; 
+"__rcu_read_unlock" [style=filled; fillcolor=orange];
 }`
 		dok = &SqlDB{}
 		db, mock, _ = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
@@ -230,18 +320,18 @@ rankdir="LR"
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and " +
 				"symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=472055 " +
 				"and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"472055", "__x64_sys_getpid", "", "kernel/sys.c"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"472055", "__x64_sys_getpid", "", "kernel/sys.c", "0xffffffff81077560"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__x64_sys_getpid' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__x64_sys_getpid' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: nil,
 		})
@@ -254,26 +344,26 @@ rankdir="LR"
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=501994 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"501994", "__fentry__", "X86 ARCHITECTURE (32-BIT AND 64-BIT)", "arch/x86/kernel/ftrace_64.S"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"501994", "__fentry__", "X86 ARCHITECTURE (32-BIT AND 64-BIT)", "arch/x86/kernel/ftrace_64.S", "0xffffffff81e01fd0"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=472243 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"472243", "__task_pid_nr_ns", "", "kernel/pid.c"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"472243", "__task_pid_nr_ns", "", "kernel/pid.c", "0xffffffff810824d0"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__task_pid_nr_ns' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__task_pid_nr_ns' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: nil,
 		})
@@ -288,26 +378,26 @@ rankdir="LR"
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=473674 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"473674", "__rcu_read_lock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"473674", "__rcu_read_lock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h", "0xffffffff810c5f20"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
-			querySTR: "select symbol_id, symbol_name, subsys_name, file_name from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
+			querySTR: "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from (select * from symbols, files where symbols.symbol_file_ref_id=files.file_id " +
 				"and symbols.symbol_instance_id_ref=16) as dummy left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where " +
 				"symbol_id=473716 and symbol_instance_id_ref=16",
-			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name"},
-			resultValues: [][]driver.Value{{"473716", "__rcu_read_unlock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h"}},
+			resultHead:   []string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"},
+			resultValues: [][]driver.Value{{"473716", "__rcu_read_unlock", "READ-COPY UPDATE (RCU)", "kernel/rcu/tree_plugin.h", "0xffffffff810c6010"}},
 		})
 
 		queryTestSerie = append(queryTestSerie, mockQueries{
 			querySTR: "select (select symbol_type from symbols where symbol_name='__rcu_read_lock' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__rcu_read_lock' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: [][]driver.Value{{"direct", "READ-COPY UPDATE (RCU)"}},
 		})
@@ -316,7 +406,7 @@ rankdir="LR"
 			querySTR: "select (select symbol_type from symbols where symbol_name='__rcu_read_unlock' and symbol_instance_id_ref=16) as type, subsys_name from " +
 				"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, tags where " +
 				"symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='__rcu_read_unlock' and symbols.symbol_instance_id_ref=16) " +
-				"group by subsys_name order by cnt desc) as tbl",
+				"group by subsys_name order by cnt desc, subsys_name) as tbl",
 			resultHead:   []string{"type", "subsys_name"},
 			resultValues: [][]driver.Value{{"direct", "READ-COPY UPDATE (RCU)"}},
 		})
@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"image"
+	"image/color"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PDF Tests", func() {
+	When("writePDF", func() {
+		It("Should produce a page sized after the image resolution", func() {
+			var b bytes.Buffer
+			img := image.NewRGBA(image.Rect(0, 0, 192, 96))
+			img.Set(0, 0, color.Black)
+
+			Expect(writePDF(&b, img, 0)).To(BeNil())
+
+			out := b.String()
+			Expect(out).To(HavePrefix("%PDF-1.4\n"))
+			Expect(out).To(ContainSubstring("/MediaBox [0 0 144.00 72.00]"))
+			Expect(out).To(ContainSubstring("/Width 192 /Height 96"))
+			Expect(out).To(HaveSuffix("%%EOF\n"))
+		})
+
+		It("Should composite the transparent pixels over white", func() {
+			var b bytes.Buffer
+			img := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+			img.Set(0, 0, color.NRGBA{0xff, 0, 0, 0x80})
+			img.Set(1, 0, color.NRGBA{0, 0, 0xff, 0xff})
+
+			Expect(writePDF(&b, img, 0)).To(BeNil())
+
+			out := b.String()
+			m := regexp.MustCompile(`/FlateDecode /Length ([0-9]+) >>\nstream\n`).FindStringSubmatchIndex(out)
+			Expect(m).ToNot(BeNil())
+			n, _ := strconv.Atoi(out[m[2]:m[3]])
+			r, err := zlib.NewReader(strings.NewReader(out[m[1] : m[1]+n]))
+			Expect(err).To(BeNil())
+			pixels, err := io.ReadAll(r)
+			Expect(err).To(BeNil())
+			Expect(pixels).To(Equal([]byte{0xff, 0x7f, 0x7f, 0, 0, 0xff, 0xff, 0xff, 0xff}))
+		})
+	})
+})
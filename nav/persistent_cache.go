@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// On disk representation of an entry.
+type cacheEntry struct {
+	Symbol     string
+	Fn         string
+	SourceRef  string
+	AddressRef string
+	Subsys     []string
+	SymId      int
+	Address    string
+}
+
+// On disk representation of the cache of a db instance.
+type cacheFile struct {
+	Fingerprint string
+	Entries     map[int]cacheEntry
+	Successors  map[int][]cacheEntry
+	SubSys      map[string]string
+}
+
+func toCacheEntry(e entry) cacheEntry {
+	return cacheEntry{e.symbol, e.fn, e.sourceRef, e.addressRef, e.subsys, e.symId, e.address}
+}
+
+func fromCacheEntry(e cacheEntry) entry {
+	return entry{symbol: e.Symbol, fn: e.Fn, sourceRef: e.SourceRef, addressRef: e.AddressRef, subsys: e.Subsys, symId: e.SymId, address: e.Address}
+}
+
+// Returns the path of the cache file for a given db instance.
+// The DSN is hashed, so that no credential ends up in the file name, and
+// different databases sharing the same instance ids do not collide.
+func cachePath(dir string, driver string, dsn string, instance int) string {
+	sum := sha256.Sum256([]byte(driver + "\x00" + dsn))
+	return filepath.Join(dir, fmt.Sprintf("%x-%d.gob", sum[:8], instance))
+}
+
+// Returns a string identifying the content of a db instance.
+// It changes when the instance is recreated, even if it reuses the same id.
+func (d *SqlDB) instanceFingerprint(instance int) (string, error) {
+	var version, note sql.NullString
+	var cnt, maxId int
+
+	query := "select version_string, note, (select count(*) from symbols where symbol_instance_id_ref=%[1]d), " +
+		"(select coalesce(max(symbol_id), 0) from symbols where symbol_instance_id_ref=%[1]d) from instances where instance_id=%[1]d"
+	query = fmt.Sprintf(query, instance)
+	debugQueryPrintln(query)
+	row := d.db.QueryRow(query)
+	if err := row.Scan(&version, &note, &cnt, &maxId); err != nil {
+		return "", fmt.Errorf("instanceFingerprint: %w", err)
+	}
+	return fmt.Sprintf("%s|%s|%d|%d", version.String, note.String, cnt, maxId), nil
+}
+
+// Loads the cache content from path.
+// Nothing is loaded if the file was produced for a different fingerprint.
+func (c *Cache) load(path string, fingerprint string) error {
+	var cf cacheFile
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&cf); err != nil {
+		return fmt.Errorf("corrupted cache file %s: %w", path, err)
+	}
+	if cf.Fingerprint != fingerprint {
+		return fmt.Errorf("stale cache file %s", path)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for id, e := range cf.Entries {
+		c.entries[id] = fromCacheEntry(e)
+	}
+	for id, list := range cf.Successors {
+		res := make([]entry, 0, len(list))
+		for _, e := range list {
+			res = append(res, fromCacheEntry(e))
+		}
+		c.successors[id] = res
+	}
+	for symbol, subsys := range cf.SubSys {
+		c.subSys[symbol] = subsys
+	}
+	return nil
+}
+
+// Stores the cache content to path, replacing any previous content.
+func (c *Cache) save(path string, fingerprint string) error {
+	cf := cacheFile{
+		Fingerprint: fingerprint,
+		Entries:     map[int]cacheEntry{},
+		Successors:  map[int][]cacheEntry{},
+		SubSys:      map[string]string{},
+	}
+
+	c.mu.RLock()
+	for id, e := range c.entries {
+		cf.Entries[id] = toCacheEntry(e)
+	}
+	for id, list := range c.successors {
+		res := make([]cacheEntry, 0, len(list))
+		for _, e := range list {
+			res = append(res, toCacheEntry(e))
+		}
+		cf.Successors[id] = res
+	}
+	for symbol, subsys := range c.subSys {
+		cf.SubSys[symbol] = subsys
+	}
+	c.mu.RUnlock()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	if err := gob.NewEncoder(tmp).Encode(&cf); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Persistent Cache Tests", func() {
+	var dir string
+	var src, dst *Cache
+	e := entry{symbol: "mysymbol", fn: "kernel/sys.c", sourceRef: "kernel/sys.c:10", addressRef: "0x10", subsys: []string{"SUB"}, symId: 1}
+
+	newCache := func() *Cache {
+		return &Cache{successors: map[int][]entry{}, entries: map[int]entry{}, subSys: map[string]string{}}
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "navcache")
+		Expect(err).To(BeNil())
+		src = newCache()
+		src.setEntry(1, e)
+		src.setSuccessors(2, []entry{e})
+		src.setSubsys("mysymbol", "SUB")
+		dst = newCache()
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	When("cachePath", func() {
+		It("Should not expose the DSN and depend on the instance", func() {
+			p1 := cachePath(dir, "postgres", "password=secret", 1)
+			p2 := cachePath(dir, "postgres", "password=secret", 2)
+
+			Expect(p1).ToNot(ContainSubstring("secret"))
+			Expect(p1).ToNot(Equal(p2))
+			Expect(filepath.Dir(p1)).To(Equal(dir))
+		})
+	})
+
+	When("save and load", func() {
+		It("Should restore the saved content", func() {
+			path := filepath.Join(dir, "sub", "cache.gob")
+			Expect(src.save(path, "fp")).To(BeNil())
+			Expect(dst.load(path, "fp")).To(BeNil())
+
+			Expect(dst.entries).To(Equal(src.entries))
+			Expect(dst.successors).To(Equal(src.successors))
+			Expect(dst.subSys).To(Equal(src.subSys))
+		})
+
+		It("Should not load the content of a different instance", func() {
+			path := filepath.Join(dir, "cache.gob")
+			Expect(src.save(path, "fp")).To(BeNil())
+
+			Expect(dst.load(path, "other")).ToNot(BeNil())
+			Expect(dst.entries).To(BeEmpty())
+		})
+
+		It("Should fail if the file does not exist", func() {
+			Expect(dst.load(filepath.Join(dir, "missing.gob"), "fp")).ToNot(BeNil())
+		})
+	})
+
+	When("instanceFingerprint", func() {
+		query := "select version_string, note, (select count(*) from symbols where symbol_instance_id_ref=1), " +
+			"(select coalesce(max(symbol_id), 0) from symbols where symbol_instance_id_ref=1) from instances where instance_id=1"
+
+		It("Should identify the instance content", func() {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version_string", "note", "cnt", "max"}).AddRow("6.1.55", nil, 10, 100))
+			d := &SqlDB{db: db}
+
+			fp, err := d.instanceFingerprint(1)
+
+			Expect(err).To(BeNil())
+			Expect(fp).To(Equal("6.1.55||10|100"))
+		})
+
+		It("Should fail if the instance does not exist", func() {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows([]string{"version_string", "note", "cnt", "max"}))
+			d := &SqlDB{db: db}
+
+			_, err := d.instanceFingerprint(1)
+
+			Expect(err).ToNot(BeNil())
+		})
+	})
+})
@@ -0,0 +1,88 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"html/template"
+	"io"
+	"nav/config"
+	"os"
+	"path/filepath"
+)
+
+// Call site shown in the html report.
+type reportSite struct {
+	SourceRef string
+	Address   string
+	Snippet   *snippet
+}
+
+// Edge shown in the html report, with all its call sites.
+type reportEdge struct {
+	jsonEdge
+	Sites []reportSite
+}
+
+// Data of the html report.
+type reportData struct {
+	Symbol    string
+	Truncated string
+	Edges     []reportEdge
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"add": func(a, b int) int { return a + b },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Call sites from {{.Symbol}}</title>
+<style>
+body { font-family: sans-serif; }
+pre { background: #f6f8fa; padding: 0.5em; }
+.call { background: #fff5b1; font-weight: bold; }
+.missing { color: #888; }
+</style>
+</head>
+<body>
+<h1>Call sites from {{.Symbol}}</h1>
+{{if .Truncated}}<p>Partial graph: {{.Truncated}}</p>
+{{end}}{{range .Edges}}<h2>{{.Caller}} &rarr; {{.Callee}}</h2>
+<p>{{.CallSites}} call site(s)</p>
+{{range .Sites}}<h3>{{.SourceRef}} ({{.Address}})</h3>
+{{with .Snippet}}<pre>{{$s := .}}{{range $i, $t := .Text}}{{$n := add $s.FirstLine $i}}<span{{if eq $n $s.Line}} class="call"{{end}}>{{printf "%5d" $n}}  {{$t}}</span>
+{{end}}</pre>
+{{else}}<p class="missing">source not available</p>
+{{end}}{{end}}{{end}}</body>
+</html>
+`))
+
+// Writes an html page showing the source of every call site of the graph.
+func writeHTMLReport(w io.Writer, conf *config.ConfValues, st *navState, src *snippetReader) error {
+	data := reportData{Symbol: conf.Symbol, Truncated: st.truncated}
+
+	for _, e := range st.edges {
+		re := reportEdge{jsonEdge: toJsonEdge(e, conf.Mode, nil)}
+		for _, s := range e.sites {
+			re.Sites = append(re.Sites, reportSite{s.sourceRef, s.addressRef, src.get(s.sourceRef)})
+		}
+		data.Edges = append(data.Edges, re)
+	}
+	return reportTemplate.Execute(w, data)
+}
+
+// Writes the html report to the file at path.
+func saveHTMLReport(path string, conf *config.ConfValues, st *navState, src *snippetReader) error {
+	f, err := os.Create(filepath.Clean(path))
+	if err != nil {
+		return err
+	}
+	if err := writeHTMLReport(f, conf, st, src); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"nav/config"
+)
+
+// Produces a report in the configured format.
+type reportFunc func(w io.Writer, d instanceSource, conf *config.ConfValues) error
+
+// Reports covering a whole instance, by name. The formats each of them
+// supports are validated by the config package.
+var reports = map[string]reportFunc{
+	"matrix":       writeMatrixReport,
+	"surface":      writeSurfaceReport,
+	"layering":     writeLayeringReport,
+	"cycles":       writeCyclesReport,
+	"unreferenced": writeUnreferencedReport,
+	"syscalls":     writeSyscallsReport,
+	"impact":       writeImpactReport,
+	"annotations":  writeAnnotationsReport,
+	"traceability": writeTraceabilityReport,
+	"requirements": writeRequirementsReport,
+}
+
+func writeReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	report, ok := reports[conf.Report]
+	if !ok {
+		return fmt.Errorf("unknown report: %s", conf.Report)
+	}
+	return report(w, d, conf)
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"nav/config"
+)
+
+// Requirement and the functions implementing it.
+type requirement struct {
+	ID          string   `yaml:"id"`
+	Description string   `yaml:"description"`
+	Functions   []string `yaml:"functions"`
+}
+
+type requirements struct {
+	List []*requirement `yaml:"requirements"`
+}
+
+// Reads a requirements mapping: a YAML file listing the requirements with
+// their functions, or a CSV file with a requirement,function row for each
+// pair, chosen by the file extension.
+func loadRequirements(path string) (*requirements, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("problem while reading requirements file: %w", err)
+	}
+	r := &requirements{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, r)
+	case ".csv":
+		err = r.parseCSV(string(data))
+	default:
+		return nil, fmt.Errorf("unsupported requirements file: %s, use a .yaml, .yml or .csv file", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("problem while parsing requirements file: %w", err)
+	}
+	for i, req := range r.List {
+		if req.ID == "" {
+			return nil, fmt.Errorf("requirement %d has no id", i+1)
+		}
+		if len(req.Functions) == 0 {
+			return nil, fmt.Errorf("requirement %d (%s) has no functions", i+1, req.ID)
+		}
+	}
+	return r, nil
+}
+
+// Reads the requirement,function rows of a CSV mapping; an optional
+// description column is kept from the first row of each requirement.
+func (r *requirements) parseCSV(data string) error {
+	cr := csv.NewReader(strings.NewReader(data))
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "requirement") {
+		rows = rows[1:]
+	}
+	byId := map[string]*requirement{}
+	for i, row := range rows {
+		if len(row) < 2 || row[0] == "" || row[1] == "" {
+			return fmt.Errorf("row %d: expected requirement,function", i+1)
+		}
+		req, ok := byId[row[0]]
+		if !ok {
+			req = &requirement{ID: row[0]}
+			if len(row) > 2 {
+				req.Description = row[2]
+			}
+			byId[row[0]] = req
+			r.List = append(r.List, req)
+		}
+		if !contains(req.Functions, row[1]) {
+			req.Functions = append(req.Functions, row[1])
+		}
+	}
+	return nil
+}
+
+// Function of a feature, with the requirements covering it.
+type coveredFunction struct {
+	Symbol       string   `json:"symbol"`
+	File         string   `json:"file"`
+	Subsys       string   `json:"subsys"`
+	Depth        int      `json:"depth"`
+	Requirements []string `json:"requirements"`
+}
+
+// Requirement pointing at functions missing from the instance.
+type staleRequirement struct {
+	ID        string   `json:"id"`
+	Functions []string `json:"functions"`
+}
+
+// Requirements coverage of the call tree of a feature.
+type requirementsCoverage struct {
+	Feature   string              `json:"feature"`
+	Instance  int                 `json:"instance"`
+	Missing   []string            `json:"missing_entries"`
+	Covered   []*coveredFunction  `json:"covered"`
+	Uncovered []*coveredFunction  `json:"uncovered"`
+	Stale     []*staleRequirement `json:"stale"`
+}
+
+// Maps the requirements on the functions of a feature, and lists those
+// whose functions do not exist in the instance at all.
+func mapRequirements(d instanceSource, instance int, t *traceability, r *requirements) (*requirementsCoverage, error) {
+	rc := &requirementsCoverage{t.Feature, instance, t.Missing, []*coveredFunction{}, []*coveredFunction{}, []*staleRequirement{}}
+
+	bySymbol := map[string][]string{}
+	for _, req := range r.List {
+		for _, f := range req.Functions {
+			if !contains(bySymbol[f], req.ID) {
+				bySymbol[f] = append(bySymbol[f], req.ID)
+			}
+		}
+	}
+	for _, f := range t.Functions {
+		cf := &coveredFunction{f.Symbol, f.File, f.Subsys, f.Depth, bySymbol[f.Symbol]}
+		if len(cf.Requirements) == 0 {
+			cf.Requirements = []string{}
+			rc.Uncovered = append(rc.Uncovered, cf)
+			continue
+		}
+		sort.Strings(cf.Requirements)
+		rc.Covered = append(rc.Covered, cf)
+	}
+
+	existing := map[string]bool{}
+	err := d.functions(instance, func(f function) error {
+		existing[f.symbol] = true
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, req := range r.List {
+		var missing []string
+		for _, f := range req.Functions {
+			if !existing[f] {
+				missing = append(missing, f)
+			}
+		}
+		if len(missing) > 0 {
+			rc.Stale = append(rc.Stale, &staleRequirement{req.ID, missing})
+		}
+	}
+	sort.SliceStable(rc.Stale, func(i, j int) bool {
+		return rc.Stale[i].ID < rc.Stale[j].ID
+	})
+	return rc, nil
+}
+
+func writeRequirementsText(w io.Writer, rc *requirementsCoverage) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Feature: %s (instance %d)\n", rc.Feature, rc.Instance)
+	if len(rc.Missing) > 0 {
+		fmt.Fprintf(&b, "Entry points not found: %s\n", strings.Join(rc.Missing, ", "))
+	}
+	fmt.Fprintf(&b, "\nCovered functions (%d):\n", len(rc.Covered))
+	for _, f := range rc.Covered {
+		fmt.Fprintf(&b, "  %s (%s) [%s]\n", f.Symbol, f.File, strings.Join(f.Requirements, ", "))
+	}
+	fmt.Fprintf(&b, "\nUncovered functions (%d):\n", len(rc.Uncovered))
+	for _, f := range rc.Uncovered {
+		fmt.Fprintf(&b, "  %s (%s)\n", f.Symbol, f.File)
+	}
+	fmt.Fprintf(&b, "\nStale requirements (%d):\n", len(rc.Stale))
+	for _, s := range rc.Stale {
+		fmt.Fprintf(&b, "  %s: %s\n", s.ID, strings.Join(s.Functions, ", "))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeRequirementsCSV(w io.Writer, rc *requirementsCoverage) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"status", "function", "file", "subsystem", "depth", "requirements"}); err != nil {
+		return err
+	}
+	for _, l := range []struct {
+		status string
+		list   []*coveredFunction
+	}{{"covered", rc.Covered}, {"uncovered", rc.Uncovered}} {
+		for _, f := range l.list {
+			err := cw.Write([]string{l.status, f.Symbol, f.File, f.Subsys, strconv.Itoa(f.Depth), strings.Join(f.Requirements, ";")})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	for _, s := range rc.Stale {
+		for _, f := range s.Functions {
+			if err := cw.Write([]string{"stale", f, "", "", "", s.ID}); err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeRequirementsReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	r, err := loadRequirements(conf.Requirements)
+	if err != nil {
+		return err
+	}
+	entries := featureEntries(conf)
+	t, err := traceFeature(d, conf.DBInstance, entries, conf.ExcludedAfter, conf.MaxDepth, nil)
+	if err != nil {
+		return err
+	}
+	t.Feature = conf.FeatureName
+	if t.Feature == "" {
+		t.Feature = strings.Join(entries, ", ")
+	}
+	rc, err := mapRequirements(d, conf.DBInstance, t, r)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(rc)
+	case "csv":
+		return writeRequirementsCSV(w, rc)
+	default:
+		return writeRequirementsText(w, rc)
+	}
+}
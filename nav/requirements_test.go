@@ -0,0 +1,104 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Requirements Tests", func() {
+	var src *fakeInstance
+	var dir string
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "sys_open", "fs/open.c", "do_open", "fs/open.c", "fs/open.c:10", "0x10"},
+				{2, 3, "do_open", "fs/open.c", "kmalloc", "mm/slab.c", "fs/open.c:30", "0x30"},
+			},
+			funcs: []function{{1, "sys_open", "fs/open.c", false}, {2, "do_open", "fs/open.c", false},
+				{3, "kmalloc", "mm/slab.c", false}, {4, "leaf", "lib/leaf.c", false}},
+			subs: map[string][]string{"fs/open.c": {"VFS"}, "mm/slab.c": {"SLAB"}},
+		}
+		var err error
+		dir, err = os.MkdirTemp("", "nav-requirements")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(os.WriteFile(path, []byte(content), 0600)).To(Succeed())
+		return path
+	}
+
+	It("Should read the same mapping from YAML and CSV", func() {
+		y, err := loadRequirements(write("req.yaml", `requirements:
+  - id: REQ-1
+    description: open files
+    functions: [sys_open, do_open]
+  - id: REQ-2
+    functions: [do_open]
+`))
+		Expect(err).To(BeNil())
+		c, err := loadRequirements(write("req.csv", "requirement,function,description\nREQ-1,sys_open,open files\nREQ-1,do_open\nREQ-2,do_open\n"))
+		Expect(err).To(BeNil())
+		Expect(c).To(Equal(y))
+	})
+
+	It("Should reject invalid mappings", func() {
+		_, err := loadRequirements(write("req.yml", "requirements:\n  - id: REQ-1\n"))
+		Expect(err).To(MatchError("requirement 1 (REQ-1) has no functions"))
+		_, err = loadRequirements(write("req.csv", "REQ-1\n"))
+		Expect(err).To(MatchError("problem while parsing requirements file: row 1: expected requirement,function"))
+		_, err = loadRequirements(write("req.txt", ""))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("Should report the covered, uncovered and stale entries", func() {
+		path := write("req.csv", "REQ-2,do_open\nREQ-1,sys_open\nREQ-1,vfs_gone\nREQ-3,leaf\n")
+		t, err := traceFeature(src, 1, []string{"sys_open"}, nil, 0, nil)
+		Expect(err).To(BeNil())
+		r, err := loadRequirements(path)
+		Expect(err).To(BeNil())
+		rc, err := mapRequirements(src, 1, t, r)
+
+		Expect(err).To(BeNil())
+		Expect(rc.Covered).To(Equal([]*coveredFunction{
+			{"sys_open", "fs/open.c", "VFS", 0, []string{"REQ-1"}},
+			{"do_open", "fs/open.c", "VFS", 1, []string{"REQ-2"}},
+		}))
+		Expect(rc.Uncovered).To(Equal([]*coveredFunction{{"kmalloc", "mm/slab.c", "SLAB", 2, []string{}}}))
+		Expect(rc.Stale).To(Equal([]*staleRequirement{{"REQ-1", []string{"vfs_gone"}}}))
+
+		buf := &bytes.Buffer{}
+		conf := config.ConfValues{DBInstance: 1, Report: "requirements", ReportFormat: "csv", Symbol: "sys_open", Requirements: path}
+		Expect(writeReport(buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("status,function,file,subsystem,depth,requirements\n" +
+			"covered,sys_open,fs/open.c,VFS,0,REQ-1\n" +
+			"covered,do_open,fs/open.c,VFS,1,REQ-2\n" +
+			"uncovered,kmalloc,mm/slab.c,SLAB,2,\n" +
+			"stale,vfs_gone,,,,REQ-1\n"))
+
+		buf.Reset()
+		conf.ReportFormat = "text"
+		Expect(writeReport(buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("Feature: sys_open (instance 1)\n\n" +
+			"Covered functions (2):\n  sys_open (fs/open.c) [REQ-1]\n  do_open (fs/open.c) [REQ-2]\n\n" +
+			"Uncovered functions (1):\n  kmalloc (mm/slab.c)\n\n" +
+			"Stale requirements (1):\n  REQ-1: vfs_gone\n"))
+	})
+})
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Table of the kern_bin_db schema and the columns nav relies on.
+type schemaTable struct {
+	name    string
+	columns []string
+}
+
+var schemaTables = []schemaTable{
+	{"instances", []string{"instance_id", "version_string", "note"}},
+	{"files", []string{"file_id", "file_name", "file_instance_id_ref"}},
+	{"symbols", []string{"symbol_id", "symbol_name", "symbol_address", "symbol_type", "symbol_file_ref_id", "symbol_instance_id_ref"}},
+	{"tags", []string{"subsys_name", "tag_file_ref_id", "tag_instance_id_ref"}},
+	{"xrefs", []string{"caller", "callee", "ref_addr", "source_line", "xref_instance_id_ref"}},
+}
+
+// Tables created only by the postgres schema of kern_bin_db. The reports
+// relying on them skip the corresponding checks when they are missing.
+var optionalTables = []schemaTable{
+	{"nm_symbol", []string{"nm_sym_id", "symbol_name", "nm_symbol_instance_id_ref"}},
+	{"data_xrefs", []string{"func_id", "data_sym_id"}},
+}
+
+var errMissingTable = errors.New("table not available, only the postgres schema of kern_bin_db has it")
+
+// Verifies that the database can be reached and has the tables and columns
+// nav needs, and records the optional tables it misses.
+func (d *SqlDB) checkSchema() error {
+	if err := d.db.Ping(); err != nil {
+		return fmt.Errorf("unable to connect to the database, check db_driver and DBDSN: %w", err)
+	}
+	for _, t := range schemaTables {
+		query := fmt.Sprintf("select %s from %s where 1=0", strings.Join(t.columns, ", "), t.name)
+		debugQueryPrintln(query)
+		rows, err := d.db.Query(query)
+		if err != nil {
+			return fmt.Errorf("the database does not look like a kern_bin_db database, or its schema is outdated: "+
+				"table %s with columns %s is required: %w", t.name, strings.Join(t.columns, ", "), err)
+		}
+		rows.Close()
+	}
+	d.missing = map[string]bool{}
+	for _, t := range optionalTables {
+		query := fmt.Sprintf("select %s from %s where 1=0", strings.Join(t.columns, ", "), t.name)
+		debugQueryPrintln(query)
+		rows, err := d.db.Query(query)
+		if err != nil {
+			d.missing[t.name] = true
+			continue
+		}
+		rows.Close()
+	}
+	return nil
+}
+
+// Returns an error wrapping errMissingTable if any of the given optional
+// tables is missing.
+func (d *SqlDB) requireTables(names ...string) error {
+	for _, n := range names {
+		if d.missing[n] {
+			return fmt.Errorf("%s: %w", n, errMissingTable)
+		}
+	}
+	return nil
+}
+
+// Tells, on the standard error, that the check described by what is skipped
+// if err is due to a missing optional table, and returns true in such case.
+func skipMissingTable(err error, what string) bool {
+	if !errors.Is(err, errMissingTable) {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "warning: %s skipped: %s\n", what, err)
+	return true
+}
+
+// Verifies that an instance exists and has data.
+func (d *SqlDB) checkInstance(instance int) error {
+	var cnt int
+
+	query := fmt.Sprintf("select count(*) from instances where instance_id=%d", instance)
+	debugQueryPrintln(query)
+	if err := d.db.QueryRow(query).Scan(&cnt); err != nil {
+		return fmt.Errorf("checkInstance: %w", err)
+	}
+	if cnt == 0 {
+		return fmt.Errorf("instance %d does not exist, use --list-instances to show the available ones", instance)
+	}
+
+	query = fmt.Sprintf("select count(*) from symbols where symbol_instance_id_ref=%d", instance)
+	debugQueryPrintln(query)
+	if err := d.db.QueryRow(query).Scan(&cnt); err != nil {
+		return fmt.Errorf("checkInstance: %w", err)
+	}
+	if cnt == 0 {
+		return fmt.Errorf("instance %d has no symbols, the database may not have been fully populated", instance)
+	}
+	return nil
+}
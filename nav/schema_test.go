@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var _ = Describe("Schema Tests", func() {
+	var db *sql.DB
+	var mock sqlmock.Sqlmock
+	var d *SqlDB
+
+	BeforeEach(func() {
+		db, mock, _ = sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+		d = &SqlDB{db: db}
+	})
+
+	AfterEach(func() {
+		db.Close()
+	})
+
+	When("checkSchema", func() {
+		It("Should accept a database with all the tables", func() {
+			for _, t := range append(append([]schemaTable{}, schemaTables...), optionalTables...) {
+				query := fmt.Sprintf("select %s from %s where 1=0", strings.Join(t.columns, ", "), t.name)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(t.columns))
+			}
+
+			Expect(d.checkSchema()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(d.missing).To(BeEmpty())
+		})
+
+		It("Should accept a database without the postgres only tables", func() {
+			for _, t := range schemaTables {
+				query := fmt.Sprintf("select %s from %s where 1=0", strings.Join(t.columns, ", "), t.name)
+				mock.ExpectQuery(query).WillReturnRows(sqlmock.NewRows(t.columns))
+			}
+			for _, t := range optionalTables {
+				query := fmt.Sprintf("select %s from %s where 1=0", strings.Join(t.columns, ", "), t.name)
+				mock.ExpectQuery(query).WillReturnError(fmt.Errorf("no such table: %s", t.name))
+			}
+
+			Expect(d.checkSchema()).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+			Expect(d.missing).To(Equal(map[string]bool{"nm_symbol": true, "data_xrefs": true}))
+
+			_, err := d.markerSymbols(1)
+			Expect(errors.Is(err, errMissingTable)).To(BeTrue())
+			Expect(err.Error()).To(HavePrefix("markerSymbols: nm_symbol: table not available"))
+			_, err = d.dataReferenced(1)
+			Expect(errors.Is(err, errMissingTable)).To(BeTrue())
+		})
+
+		It("Should name the missing table", func() {
+			mock.ExpectQuery("select instance_id, version_string, note from instances where 1=0").
+				WillReturnRows(sqlmock.NewRows([]string{"instance_id", "version_string", "note"}))
+			mock.ExpectQuery("select file_id, file_name, file_instance_id_ref from files where 1=0").
+				WillReturnError(fmt.Errorf("no such table: files"))
+
+			err := d.checkSchema()
+
+			Expect(err).ToNot(BeNil())
+			Expect(err.Error()).To(ContainSubstring("table files with columns file_id, file_name, file_instance_id_ref is required: no such table: files"))
+		})
+	})
+
+	When("checkInstance", func() {
+		countQuery := "select count(*) from instances where instance_id=3"
+		symbolsQuery := "select count(*) from symbols where symbol_instance_id_ref=3"
+
+		It("Should refuse a missing instance", func() {
+			mock.ExpectQuery(countQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			Expect(d.checkInstance(3)).To(MatchError("instance 3 does not exist, use --list-instances to show the available ones"))
+		})
+
+		It("Should refuse an empty instance", func() {
+			mock.ExpectQuery(countQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectQuery(symbolsQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+
+			Expect(d.checkInstance(3)).To(MatchError("instance 3 has no symbols, the database may not have been fully populated"))
+		})
+
+		It("Should accept an instance with data", func() {
+			mock.ExpectQuery(countQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+			mock.ExpectQuery(symbolsQuery).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(10))
+
+			Expect(d.checkInstance(3)).To(Succeed())
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+	})
+})
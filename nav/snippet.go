@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bufio"
+	"nav/config"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Lines of context shown around a call site when none is configured.
+const defaultSnippetLines = 3
+
+// Source lines around a call site.
+type snippet struct {
+	File      string   `json:"file"`
+	Line      int      `json:"line"`
+	FirstLine int      `json:"first_line"`
+	Text      []string `json:"text"`
+}
+
+// Reads call site snippets from a local kernel source tree.
+// Source files are read once and kept in memory.
+type snippetReader struct {
+	root    string
+	prefix  string
+	context int
+	files   map[string][]string
+}
+
+// Returns the snippet reader for the given configuration, nil if no source
+// tree is configured.
+func newSnippetReader(conf *config.ConfValues) *snippetReader {
+	if conf.SourceTree == "" {
+		return nil
+	}
+	context := conf.SnippetLines
+	if context == 0 {
+		context = defaultSnippetLines
+	}
+	return &snippetReader{conf.SourceTree, conf.SourcePrefix, context, map[string][]string{}}
+}
+
+// Returns the content of a source file, nil if it can not be read.
+// The path recorded at build time is made relative to the source tree, and
+// can not point outside of it.
+func (r *snippetReader) lines(file string) []string {
+	if lines, ok := r.files[file]; ok {
+		return lines
+	}
+
+	var lines []string
+	rel := filepath.Clean("/" + strings.TrimPrefix(file, r.prefix))
+	f, err := os.Open(filepath.Join(r.root, rel))
+	if err != nil {
+		debugIOPrintln("source not available:", err)
+		r.files[file] = nil
+		return nil
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		lines = append(lines, s.Text())
+	}
+	if err := s.Err(); err != nil {
+		debugIOPrintln("source not available:", err)
+		lines = nil
+	}
+	r.files[file] = lines
+	return lines
+}
+
+// Returns the snippet of a call site in the "file:line" form, nil if it is
+// not available.
+func (r *snippetReader) get(sourceRef string) *snippet {
+	if r == nil {
+		return nil
+	}
+	file, l := splitSourceRef(sourceRef)
+	line, err := strconv.Atoi(l)
+	if err != nil || line < 1 {
+		return nil
+	}
+	lines := r.lines(file)
+	if line > len(lines) {
+		return nil
+	}
+
+	first := line - r.context
+	if first < 1 {
+		first = 1
+	}
+	last := line + r.context
+	if last > len(lines) {
+		last = len(lines)
+	}
+	return &snippet{file, line, first, lines[first-1 : last]}
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"nav/config"
+	c "nav/constants"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Snippet Tests", func() {
+	var root string
+	var conf config.ConfValues
+
+	BeforeEach(func() {
+		var err error
+		root, err = os.MkdirTemp("", "navsrc")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(root, "kernel"), 0755)).To(Succeed())
+		Expect(os.WriteFile(filepath.Join(root, "kernel", "a.c"), []byte("1\n2\n3\n4\n5\n6\n"), 0644)).To(Succeed())
+		conf = config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, SourceTree: root, SnippetLines: 1}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	When("newSnippetReader", func() {
+		It("Should return nil if no source tree is configured", func() {
+			Expect(newSnippetReader(&config.ConfValues{})).To(BeNil())
+		})
+	})
+
+	When("get", func() {
+		It("Should return the call site line and its context", func() {
+			res := newSnippetReader(&conf).get("kernel/a.c:3")
+
+			Expect(res).To(Equal(&snippet{"kernel/a.c", 3, 2, []string{"2", "3", "4"}}))
+		})
+
+		It("Should clip the context at the file boundaries", func() {
+			res := newSnippetReader(&conf).get("kernel/a.c:6")
+
+			Expect(res).To(Equal(&snippet{"kernel/a.c", 6, 5, []string{"5", "6"}}))
+		})
+
+		It("Should remove the build prefix from the file name", func() {
+			conf.SourcePrefix = "/build/linux/"
+
+			Expect(newSnippetReader(&conf).get("/build/linux/kernel/a.c:1")).ToNot(BeNil())
+		})
+
+		It("Should not read outside of the source tree", func() {
+			Expect(newSnippetReader(&conf).get("../" + filepath.Base(root) + "/kernel/a.c:1")).To(BeNil())
+		})
+
+		It("Should return nil for unknown files and lines", func() {
+			r := newSnippetReader(&conf)
+
+			Expect(r.get("kernel/b.c:1")).To(BeNil())
+			Expect(r.get("kernel/a.c:7")).To(BeNil())
+			Expect(r.get("NONE")).To(BeNil())
+		})
+	})
+
+	When("writeOutput", func() {
+		var d *sqlMock
+
+		BeforeEach(func() {
+			d = &sqlMock{}
+			d.init(nil)
+			d.LOADsym2numValues("a", 1, 1, nil)
+			d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", symId: 1}, nil)
+			d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+				{symbol: "b", symId: 2, sourceRef: "kernel/a.c:3", addressRef: "0x10"},
+				{symbol: "b", symId: 2, sourceRef: "kernel/a.c:5", addressRef: "0x20"},
+			}, nil)
+		})
+
+		It("Should attach the snippets of every call site to the json lines edges", func() {
+			var b bytes.Buffer
+			conf.Type = "jsonLines"
+
+			Expect(writeOutput(&b, d, &config.Config{ConfValues: conf})).To(Succeed())
+
+			Expect(b.String()).To(HavePrefix(`{"type":"edge","caller":"a","callee":"b","call_sites":2,"symbol_pairs":1,` +
+				`"snippets":[{"file":"kernel/a.c","line":3,"first_line":2,"text":["2","3","4"]},` +
+				`{"file":"kernel/a.c","line":5,"first_line":4,"text":["4","5","6"]}],"depth":1}`))
+		})
+
+		It("Should write the html report", func() {
+			conf.Type = "graphOnly"
+			conf.HTMLReport = filepath.Join(root, "report.html")
+
+			Expect(writeOutput(&bytes.Buffer{}, d, &config.Config{ConfValues: conf})).To(Succeed())
+
+			report, err := os.ReadFile(conf.HTMLReport)
+			Expect(err).To(BeNil())
+			Expect(string(report)).To(ContainSubstring("<h2>a &rarr; b</h2>"))
+			Expect(string(report)).To(ContainSubstring("<span class=\"call\">    3  3</span>"))
+			Expect(string(report)).To(ContainSubstring("<h3>kernel/a.c:5 (0x20)</h3>"))
+			Expect(string(report)).To(ContainSubstring("<span class=\"call\">    5  5</span>"))
+		})
+	})
+})
@@ -6,10 +6,13 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
@@ -22,15 +25,88 @@ type connectToken struct {
 	DBDSN    string
 }
 
+// Query results cache, safe for concurrent use.
 type Cache struct {
-	successors map[int][]entry
-	entries    map[int]entry
-	subSys     map[string]string
+	mu           sync.RWMutex
+	successors   map[int][]entry
+	predecessors map[int][]entry
+	entries      map[int]entry
+	subSys       map[string]string
+}
+
+func (c *Cache) getSuccessors(symbolId int) ([]entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.successors[symbolId]
+	return res, ok
+}
+
+func (c *Cache) setSuccessors(symbolId int, res []entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.successors[symbolId] = res
+}
+
+func (c *Cache) getPredecessors(symbolId int) ([]entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.predecessors[symbolId]
+	return res, ok
+}
+
+func (c *Cache) setPredecessors(symbolId int, res []entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.predecessors == nil {
+		c.predecessors = make(map[int][]entry)
+	}
+	c.predecessors[symbolId] = res
+}
+
+func (c *Cache) getEntry(symbolId int) (entry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	e, ok := c.entries[symbolId]
+	return e, ok
+}
+
+func (c *Cache) setEntry(symbolId int, e entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[symbolId] = e
+}
+
+func (c *Cache) getSubsys(symbol string) (string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	res, ok := c.subSys[symbol]
+	return res, ok
+}
+
+func (c *Cache) setSubsys(symbol string, subsys string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subSys[symbol] = subsys
 }
 
 type SqlDB struct {
-	db    *sql.DB
-	cache Cache
+	db      *sql.DB
+	cache   Cache
+	ctx     context.Context
+	missing map[string]bool
+}
+
+// Binds the following queries to ctx, so that they are interrupted when it
+// is done. A nil ctx makes them unbound again.
+func (d *SqlDB) bindContext(ctx context.Context) {
+	d.ctx = ctx
+}
+
+func (d *SqlDB) queryContext() context.Context {
+	if d.ctx == nil {
+		return context.Background()
+	}
+	return d.ctx
 }
 
 // Connects the target db and returns the handle.
@@ -48,8 +124,10 @@ func (d *SqlDB) init(arg interface{}) (err error) {
 	}
 	if err == nil {
 		d.cache.successors = make(map[int][]entry)
+		d.cache.predecessors = make(map[int][]entry)
 		d.cache.entries = make(map[int]entry)
 		d.cache.subSys = make(map[string]string)
+		err = d.checkSchema()
 	}
 	return err
 }
@@ -57,26 +135,27 @@ func (d *SqlDB) init(arg interface{}) (err error) {
 func (d *SqlDB) GetExploredSubsystemByName(subs string) string {
 	debugIOPrintln("input subs=", subs)
 	debugIOPrintln("output =", subs)
-	return d.cache.subSys[subs]
+	res, _ := d.cache.getSubsys(subs)
+	return res
 }
 
 // Returns function details from a given id.
 func (d *SqlDB) getEntryById(symbolId int, instance int) (entry, error) {
 	var e entry
-	var s sql.NullString
+	var s, addr sql.NullString
 
 	debugIOPrintf("input symbolId=%d, instance=%d\n", symbolId, instance)
-	if e, ok := d.cache.entries[symbolId]; ok {
+	if e, ok := d.cache.getEntry(symbolId); ok {
 		debugIOPrintf("output entry=%+v, error=%s\n", e, "nil")
 		return e, nil
 	}
 
-	query := "select symbol_id, symbol_name, subsys_name, file_name from " +
+	query := "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from " +
 		"(select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and symbols.symbol_instance_id_ref=%[2]d) as dummy " +
 		"left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=%[1]d and symbol_instance_id_ref=%[2]d"
 	query = fmt.Sprintf(query, symbolId, instance)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
 		debugIOPrintf("output entry=%+v, error=%s\n", entry{}, err)
 		return entry{}, err
@@ -89,10 +168,11 @@ func (d *SqlDB) getEntryById(symbolId int, instance int) (entry, error) {
 	}()
 
 	for rows.Next() {
-		if err := rows.Scan(&e.symId, &e.symbol, &s, &e.fn); err != nil {
+		if err := rows.Scan(&e.symId, &e.symbol, &s, &e.fn, &addr); err != nil {
 			debugIOPrintf("output entry=%+v, error=%s\n", entry{}, err)
 			return e, err
 		}
+		e.address = addr.String
 		if s.Valid {
 			e.subsys = append(e.subsys, s.String)
 		}
@@ -101,18 +181,43 @@ func (d *SqlDB) getEntryById(symbolId int, instance int) (entry, error) {
 		debugIOPrintf("output entry=%+v, error=%s\n", entry{}, err)
 		return e, err
 	}
-	d.cache.entries[symbolId] = e
+	sort.Strings(e.subsys)
+	d.cache.setEntry(symbolId, e)
 	debugIOPrintf("output entry=%+v, error=%s\n", e, "nil")
 	return e, nil
 }
 
+// Reads the calls of a query on xrefs. The rows are all read and closed
+// before returning, so that their connection is released before the entries
+// of the calls are queried.
+func readCalls(rows *sql.Rows) (res []edge, err error) {
+	var e edge
+
+	defer func() {
+		closeErr := rows.Close()
+		if err == nil {
+			err = closeErr
+		}
+	}()
+
+	for rows.Next() {
+		if err := rows.Scan(&e.caller, &e.callee, &e.sourceRef, &e.addressRef); err != nil {
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
 // Returns the list of successors (called function) for a given function.
 func (d *SqlDB) getSuccessorsById(symbolId int, instance int) ([]entry, error) {
-	var e edge
 	var res []entry
 
 	debugIOPrintf("input symbolId=%d, instance=%d\n", symbolId, instance)
-	if res, ok := d.cache.successors[symbolId]; ok {
+	if res, ok := d.cache.getSuccessors(symbolId); ok {
 		debugIOPrintf("output []entry=%+v, error=%s\n", res, "nil")
 		return res, nil
 	}
@@ -120,32 +225,66 @@ func (d *SqlDB) getSuccessorsById(symbolId int, instance int) ([]entry, error) {
 	query := "select caller, callee, source_line, ref_addr from xrefs where caller = %[1]d and xref_instance_id_ref = %[2]d"
 	query = fmt.Sprintf(query, symbolId, instance)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
-		panic(err)
+		debugIOPrintf("output []entry=%+v, error=%s\n", nil, err)
+		return nil, fmt.Errorf("getSuccessorsById: %w", err)
+	}
+	calls, err := readCalls(rows)
+	if err != nil {
+		debugIOPrintf("output []entry=%+v, error=%s\n", nil, err)
+		return nil, err
 	}
-	defer func() {
-		closeErr := rows.Close()
-		if err == nil {
-			err = closeErr
-		}
-	}()
 
-	for rows.Next() {
-		if err := rows.Scan(&e.caller, &e.callee, &e.sourceRef, &e.addressRef); err != nil {
-			debugIOPrintf("output []entry=%+v, error=%s\n", nil, err)
+	for _, e := range calls {
+		successor, err := d.getEntryById(e.callee, instance)
+		if err != nil {
 			return nil, err
 		}
-		successor, _ := d.getEntryById(e.callee, instance)
 		successor.sourceRef = e.sourceRef
 		successor.addressRef = e.addressRef
 		res = append(res, successor)
 	}
-	if err = rows.Err(); err != nil {
+	d.cache.setSuccessors(symbolId, res)
+	debugIOPrintf("output []entry=%+v, error=%s\n", res, "nil")
+	return res, nil
+}
+
+// Returns the list of predecessors (calling function) for a given function.
+// The source and address references are the ones of the call in the caller.
+func (d *SqlDB) getPredecessorsById(symbolId int, instance int) ([]entry, error) {
+	var res []entry
+
+	debugIOPrintf("input symbolId=%d, instance=%d\n", symbolId, instance)
+	if res, ok := d.cache.getPredecessors(symbolId); ok {
+		debugIOPrintf("output []entry=%+v, error=%s\n", res, "nil")
+		return res, nil
+	}
+
+	query := "select caller, callee, source_line, ref_addr from xrefs where callee = %[1]d and xref_instance_id_ref = %[2]d"
+	query = fmt.Sprintf(query, symbolId, instance)
+	debugQueryPrintln(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
+	if err != nil {
 		debugIOPrintf("output []entry=%+v, error=%s\n", nil, err)
 		return nil, err
 	}
-	d.cache.successors[symbolId] = res
+	calls, err := readCalls(rows)
+	if err != nil {
+		debugIOPrintf("output []entry=%+v, error=%s\n", nil, err)
+		return nil, err
+	}
+
+	for _, e := range calls {
+		predecessor, err := d.getEntryById(e.caller, instance)
+		if err != nil {
+			return nil, err
+		}
+		predecessor.sourceRef = e.sourceRef
+		predecessor.addressRef = e.addressRef
+		res = append(res, predecessor)
+	}
+	d.cache.setPredecessors(symbolId, res)
 	debugIOPrintf("output []entry=%+v, error=%s\n", res, "nil")
 	return res, nil
 }
@@ -155,20 +294,21 @@ func (d *SqlDB) getSubsysFromSymbolName(symbol string, instance int) (string, er
 	var ty, sub string
 
 	debugIOPrintf("input symbol=%s, instance=%d\n", symbol, instance)
-	if res, ok := d.cache.subSys[symbol]; ok {
+	if res, ok := d.cache.getSubsys(symbol); ok {
 		debugIOPrintf("output  string=%s, error=%s\n", res, "nil")
 		return res, nil
 	}
 	query := "select (select symbol_type from symbols where symbol_name='%[1]s' and symbol_instance_id_ref=%[2]d) as type, subsys_name from " +
 		"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, " +
 		"tags where symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='%[1]s' and symbols.symbol_instance_id_ref=%[2]d) " +
-		"group by subsys_name order by cnt desc) as tbl"
+		"group by subsys_name order by cnt desc, subsys_name) as tbl"
 
 	query = fmt.Sprintf(query, symbol, instance)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
-		panic(err)
+		debugIOPrintf("output  string=%s, error=%s\n", "", err)
+		return "", fmt.Errorf("getSubsysFromSymbolName: %w", err)
 	}
 	defer func() {
 		closeErr := rows.Close()
@@ -197,7 +337,7 @@ func (d *SqlDB) getSubsysFromSymbolName(symbol string, instance int) (string, er
 	if ty == "indirect" {
 		sub = ty
 	}
-	d.cache.subSys[symbol] = sub
+	d.cache.setSubsys(symbol, sub)
 	debugIOPrintf("output  string=%s, error=%s\n", sub, "nil")
 	return sub, nil
 }
@@ -211,9 +351,10 @@ func (d *SqlDB) sym2num(symb string, instance int) (int, error) {
 	query := "select symbol_id from symbols where symbols.symbol_name='%[1]s' and symbols.symbol_instance_id_ref=%[2]d"
 	query = fmt.Sprintf(query, symb, instance)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
-		panic(err)
+		debugIOPrintf("output int=%d, error=%s\n", -1, err)
+		return -1, fmt.Errorf("sym2num: %w", err)
 	}
 	defer func() {
 		closeErr := rows.Close()
@@ -249,6 +390,8 @@ func (d *SqlDB) symbSubsys(symblist []int, instance int) (string, error) {
 
 	debugIOPrintf("input symblist=%+v, instance=%d\n", symblist, instance)
 	for _, symbid := range symblist {
+		var names []string
+
 		// Resolve symb.
 		symb, err := d.getEntryById(symbid, instance)
 		if err != nil {
@@ -257,7 +400,7 @@ func (d *SqlDB) symbSubsys(symblist []int, instance int) (string, error) {
 		out += fmt.Sprintf("{\"FuncName\":\"%s\", \"subsystems\":[", symb.symbol)
 		query := fmt.Sprintf("select subsys_name from tags where tag_file_ref_id= (select symbol_file_ref_id from symbols where symbol_id=%d)", symbid)
 		debugQueryPrintln(query)
-		rows, err := d.db.Query(query)
+		rows, err := d.db.QueryContext(d.queryContext(), query)
 		if err != nil {
 			err = errors.New("symbSubsys: query failed")
 			debugIOPrintf("output string=%s, error=%s\n", "", err)
@@ -277,7 +420,11 @@ func (d *SqlDB) symbSubsys(symblist []int, instance int) (string, error) {
 				debugIOPrintf("output string=%s, error=%s\n", "", err)
 				return "", err
 			}
-			out += fmt.Sprintf("\"%s\",", res)
+			names = append(names, res)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			out += fmt.Sprintf("\"%s\",", name)
 		}
 		out = strings.TrimSuffix(out, ",") + "]},"
 	}
@@ -294,7 +441,7 @@ func (d *SqlDB) symbGData(symb string, instance int) ([]string, error) {
 
 	query := fmt.Sprintf("select symbol_name from nm_symbol where nm_sym_id in (select data_sym_id from data_xrefs where func_id in (select symbol_id from symbols where symbol_name ='%s' and symtype!=1 and symbol_instance_id_ref=%d));", symb, instance)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
 		err = errors.New("symbGData: query failed")
 		debugIOPrintf("output string=%s, error=%s\n", "", err)
@@ -315,6 +462,7 @@ func (d *SqlDB) symbGData(symb string, instance int) ([]string, error) {
 		}
 		out = append(out,res)
 	}
+	sort.Strings(out)
 	return out, nil
 }
 // returns a premade edge for func 
@@ -326,7 +474,7 @@ func (d *SqlDB) symbGDataFuncOf(symb string, instance int) []string {
 	query := fmt.Sprintf("select symbol_name from symbols where symbol_id in (select func_id from data_xrefs where data_sym_id in (select nm_sym_id from nm_symbol where nm_symbol_instance_id_ref = %d and symbol_name = '%s'));", instance, symb)
 //	fmt.Println(query)
 	debugQueryPrintln(query)
-	rows, err := d.db.Query(query)
+	rows, err := d.db.QueryContext(d.queryContext(), query)
 	if err != nil {
 		debugIOPrintf("output string=%s, error=symbGData: query failed\n", "")
 		return []string{}
@@ -344,5 +492,6 @@ func (d *SqlDB) symbGDataFuncOf(symb string, instance int) []string {
 		}
 		out = append(out, fmt.Sprintf("\"%s\" -> \"%s\"", res, symb))
 	}
+	sort.Strings(out)
 	return out
 }
@@ -6,10 +6,12 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -48,12 +50,42 @@ var _ = Describe("Psql Tests", func() {
 		defer db.Close()
 	})
 
+	When("Cache", func() {
+		It("Should be safe for concurrent use", func() {
+			cache := &Cache{
+				successors: map[int][]entry{},
+				entries:    map[int]entry{},
+				subSys:     map[string]string{},
+			}
+			done := make(chan bool)
+			for i := 0; i < 8; i++ {
+				go func(i int) {
+					cache.setEntry(i, e)
+					cache.setSuccessors(i, []entry{e})
+					cache.setSubsys(fmt.Sprint(i), "subsys")
+					cache.getEntry(i)
+					cache.getSuccessors(i)
+					cache.getSubsys(fmt.Sprint(i))
+					done <- true
+				}(i)
+			}
+			for i := 0; i < 8; i++ {
+				<-done
+			}
+
+			res, ok := cache.getSubsys("7")
+			Expect(ok).To(BeTrue())
+			Expect(res).To(Equal("subsys"))
+			Expect(cache.entries).To(HaveLen(8))
+		})
+	})
+
 	When("connectDB", func() {
 		// TODO: `psql.connectDB` fn refactor needed
 	})
 
 	When("getEntryById", func() {
-		testQuery := "select symbol_id, symbol_name, subsys_name, file_name from " +
+		testQuery := "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from " +
 			"(select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and symbols.symbol_instance_id_ref=0) as dummy " +
 			"left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=0 and symbol_instance_id_ref=0"
 		It("Should return a cached result", func() {
@@ -146,8 +178,9 @@ var _ = Describe("Psql Tests", func() {
 				"fn",
 				"subsys",
 				"symId",
+				"address",
 			})
-			rows.AddRow("0", "1", "2", 3)
+			rows.AddRow("0", "1", "2", 3, "0xffffffff81000000")
 
 			mock.
 				ExpectQuery(testQuery).
@@ -164,12 +197,48 @@ var _ = Describe("Psql Tests", func() {
 				addressRef: "",
 				subsys:     []string{"2"},
 				symId:      0,
+				address:    "0xffffffff81000000",
 			}
 			Expect(err).To(BeNil())
 			Expect(_entry).To(Equal(expectedEntry))
 		})
 	})
 
+	When("getPredecessorsById", func() {
+
+		testQuery := "select caller, callee, source_line, ref_addr from xrefs where callee = 0 and xref_instance_id_ref = 0"
+
+		It("Should return cached predecessors", func() {
+			dko.cache.predecessors = map[int][]entry{1: {e}}
+			entries, err := dko.getPredecessorsById(1, 1)
+
+			Expect(err).To(BeNil())
+			Expect(entries).To(Equal([]entry{e}))
+		})
+
+		It("Should return the error of the query", func() {
+			mock.ExpectQuery(testQuery).
+				WillReturnError(fmt.Errorf("myerror"))
+
+			_, err := dok.getPredecessorsById(0, 0)
+
+			Expect(err).To(Equal(fmt.Errorf("myerror")))
+		})
+
+		It("Should return the callers with the call references", func() {
+			rows := sqlmock.NewRows([]string{"caller", "callee", "source_line", "ref_addr"})
+			rows.AddRow(1, 0, "kernel/a.c:3", "0x10")
+			mock.ExpectQuery(testQuery).WillReturnRows(rows)
+			dok.cache.entries = map[int]entry{1: {symbol: "a", fn: "kernel/a.c", symId: 1}}
+
+			entries, err := dok.getPredecessorsById(0, 0)
+
+			Expect(err).To(BeNil())
+			Expect(entries).To(Equal([]entry{{symbol: "a", fn: "kernel/a.c", sourceRef: "kernel/a.c:3", addressRef: "0x10", symId: 1}}))
+			Expect(mock.ExpectationsWereMet()).To(BeNil())
+		})
+	})
+
 	When("getSuccessorsById", func() {
 
 		testQuery := "select caller, callee, source_line, ref_addr from xrefs where caller = 0 and xref_instance_id_ref = 0"
@@ -183,14 +252,14 @@ var _ = Describe("Psql Tests", func() {
 			Expect(entries).To(Equal([]entry{e}))
 		})
 
-		It("Should panic because of a query error", func() {
+		It("Should return an error because of a query error", func() {
 			mock.ExpectQuery(testQuery).
-				WithArgs(0, 0).
 				WillReturnError(fmt.Errorf("myerror"))
 			mock.ExpectRollback()
 
 			dok.cache = Cache{}
-			Expect(func() { dok.getSuccessorsById(0, 0) }).To(Panic())
+			_, err := dok.getSuccessorsById(0, 0)
+			Expect(err).To(MatchError("getSuccessorsById: myerror"))
 		})
 
 		It("Should not fail in case no records are found", func() {
@@ -256,6 +325,28 @@ var _ = Describe("Psql Tests", func() {
 			Expect(entries).To(BeNil())
 		})
 
+		It("Should release the connection of the calls before querying their entries", func() {
+			entryQuery := "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from " +
+				"(select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and symbols.symbol_instance_id_ref=0) as dummy " +
+				"left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=0 and symbol_instance_id_ref=0"
+			mock.ExpectQuery(testQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"caller", "callee", "source_line", "ref_addr"}).AddRow(0, 0, "a.c:1", "0x1"))
+			mock.ExpectQuery(entryQuery).
+				WillReturnRows(sqlmock.NewRows([]string{"symbol_id", "symbol_name", "subsys_name", "file_name", "symbol_address"}).AddRow(0, "a", nil, "a.c", nil))
+			db.SetMaxOpenConns(1)
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			dok.bindContext(ctx)
+			dok.cache.successors = map[int][]entry{}
+			dok.cache.entries = map[int]entry{}
+
+			entries, err := dok.getSuccessorsById(0, 0)
+
+			Expect(err).To(BeNil())
+			Expect(entries).To(Equal([]entry{{symbol: "a", fn: "a.c", sourceRef: "a.c:1", addressRef: "0x1"}}))
+			Expect(mock.ExpectationsWereMet()).To(Succeed())
+		})
+
 		It("Should return a list of entries", func() {
 			rows := sqlmock.NewRows([]string{
 				"symbol",
@@ -274,6 +365,7 @@ var _ = Describe("Psql Tests", func() {
 			expected := entry{symbol: "", fn: "", sourceRef: "2", addressRef: "3", subsys: nil, symId: 0}
 
 			dok.cache.successors = map[int][]entry{}
+			dok.cache.entries = map[int]entry{1: {}}
 			entries, err := dok.getSuccessorsById(0, 0)
 
 			Expect(err).To(BeNil())
@@ -285,7 +377,7 @@ var _ = Describe("Psql Tests", func() {
 		testQuery := "select (select symbol_type from symbols where symbol_name='mysym_key' and symbol_instance_id_ref=0) as type, subsys_name from " +
 			"(select count(*) as cnt, subsys_name from tags where subsys_name in (select subsys_name from symbols, " +
 			"tags where symbols.symbol_file_ref_id=tags.tag_file_ref_id and symbols.symbol_name='mysym_key' and symbols.symbol_instance_id_ref=0) " +
-			"group by subsys_name order by cnt desc) as tbl"
+			"group by subsys_name order by cnt desc, subsys_name) as tbl"
 
 		It("Should return a cached symbol", func() {
 			dko.cache.subSys = map[string]string{"mysym_key": "mysym_val"}
@@ -295,14 +387,14 @@ var _ = Describe("Psql Tests", func() {
 			Expect(sym).To(Equal("mysym_val"))
 		})
 
-		It("Should panic because of a query error", func() {
+		It("Should return an error because of a query error", func() {
 			mock.ExpectQuery(testQuery).
-				WithArgs("mysym_key", 0).
 				WillReturnError(fmt.Errorf("myerror"))
 			mock.ExpectRollback()
 			dok.cache.subSys = map[string]string{}
 
-			Expect(func() { dok.getSubsysFromSymbolName("mysym_key", 0) }).To(Panic())
+			_, err := dok.getSubsysFromSymbolName("mysym_key", 0)
+			Expect(err).To(MatchError("getSubsysFromSymbolName: myerror"))
 		})
 
 		It("Should return nil in case of no rows", func() {
@@ -409,13 +501,13 @@ var _ = Describe("Psql Tests", func() {
 	When("sym2num", func() {
 		testQuery := "select symbol_id from symbols where symbols.symbol_name='mysym_key' and symbols.symbol_instance_id_ref=0"
 
-		It("Should panic because of a query error", func() {
+		It("Should return an error because of a query error", func() {
 			mock.ExpectQuery(testQuery).
-				WithArgs("mysim", 0).
 				WillReturnError(fmt.Errorf("myerror"))
 			mock.ExpectRollback()
 
-			Expect(func() { dok.sym2num("mysym_key", 0) }).To(Panic())
+			_, err := dok.sym2num("mysym_key", 0)
+			Expect(err).To(MatchError("sym2num: myerror"))
 		})
 
 		It("Should return a row scan error", func() {
@@ -503,7 +595,7 @@ var _ = Describe("Psql Tests", func() {
 	Describe("symbSubsys", func() {
 		var symList []int
 		var instance int
-		entryIdTestQuery := "select symbol_id, symbol_name, subsys_name, file_name from " +
+		entryIdTestQuery := "select symbol_id, symbol_name, subsys_name, file_name, symbol_address from " +
 			"(select * from symbols, files where symbols.symbol_file_ref_id=files.file_id and symbols.symbol_instance_id_ref=0) as dummy " +
 			"left outer join tags on dummy.symbol_file_ref_id=tags.tag_file_ref_id where symbol_id=0 and symbol_instance_id_ref=0"
 		testQuery := "select subsys_name from tags where tag_file_ref_id= (select symbol_file_ref_id from symbols where symbol_id=0)"
@@ -513,8 +605,9 @@ var _ = Describe("Psql Tests", func() {
 				"fn",
 				"subsys",
 				"symId",
+				"address",
 			})
-			entryRows.AddRow("0", "1", "2", 0)
+			entryRows.AddRow("0", "1", "2", 0, nil)
 
 			mock.ExpectQuery(entryIdTestQuery).
 				WillReturnRows(entryRows)
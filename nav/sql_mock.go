@@ -27,6 +27,7 @@ type entryT struct {
 type sqlMock struct {
 	GetExploredSubsystemByNameValues map[string]string
 	getSuccessorsByIdValues          map[string]successorsT
+	getPredecessorsByIdValues        map[string]successorsT
 	getSubsysFromSymbolNameValues    map[string]subsysnameT
 	sym2numValues                    map[string]numT
 	symbSubsysValues                 map[string]subsysnameT
@@ -42,6 +43,11 @@ func (d *sqlMock) LOADgetSuccessorsByIdValues(symbolId int, instance int, es []e
 	d.getSuccessorsByIdValues[key] = successorsT{es, err}
 }
 
+func (d *sqlMock) LOADgetPredecessorsByIdValues(symbolId int, instance int, es []entry, err error) {
+	key := fmt.Sprintf("%04x%02x", symbolId, instance)
+	d.getPredecessorsByIdValues[key] = successorsT{es, err}
+}
+
 func (d *sqlMock) LOADgetSubsysFromSymbolNameValues(symbol string, instance int, subsysN string, err error) {
 	key := fmt.Sprintf("%s%02x", symbol, instance)
 	d.getSubsysFromSymbolNameValues[key] = subsysnameT{subsysN, err}
@@ -66,6 +72,7 @@ func (d *sqlMock) LOADgetEntryByIdValues(symbolId int, instance int, e entry, er
 func (d *sqlMock) init(arg interface{}) (err error) {
 	d.GetExploredSubsystemByNameValues = make(map[string]string, 100)
 	d.getSuccessorsByIdValues = make(map[string]successorsT, 100)
+	d.getPredecessorsByIdValues = make(map[string]successorsT, 100)
 	d.getSubsysFromSymbolNameValues = make(map[string]subsysnameT, 100)
 	d.sym2numValues = make(map[string]numT, 100)
 	d.symbSubsysValues = make(map[string]subsysnameT, 100)
@@ -89,6 +96,15 @@ func (d *sqlMock) getSuccessorsById(symbolId int, instance int) ([]entry, error)
 	return app1, app2
 }
 
+func (d *sqlMock) getPredecessorsById(symbolId int, instance int) ([]entry, error) {
+	debugIOPrintf("input symbolId=%d, instance=%d\n", symbolId, instance)
+	key := fmt.Sprintf("%04x%02x", symbolId, instance)
+	app1 := d.getPredecessorsByIdValues[key].es
+	app2 := d.getPredecessorsByIdValues[key].err
+	debugIOPrintf("output []entry=%+v, error=%s\n", app1, app2)
+	return app1, app2
+}
+
 func (d *sqlMock) getSubsysFromSymbolName(symbol string, instance int) (string, error) {
 	debugIOPrintf("input symbol=%s, instance=%d\n", symbol, instance)
 	key := fmt.Sprintf("%s%02x", symbol, instance)
@@ -125,6 +141,16 @@ func (d *sqlMock) getEntryById(symbolId int, instance int) (entry, error) {
 	return app1, app2
 }
 
+func (d *sqlMock) symbGData(symb string, instance int) ([]string, error) {
+	debugIOPrintf("input symbol=%s, instance=%d\n", symb, instance)
+	return []string{}, nil
+}
+
+func (d *sqlMock) symbGDataFuncOf(symb string, instance int) []string {
+	debugIOPrintf("input symbol=%s, instance=%d\n", symb, instance)
+	return []string{}
+}
+
 func murmurHash3(arr []int) uint32 {
 	const (
 		seed = 0x9747b28c
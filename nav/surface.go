@@ -0,0 +1,223 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nav/config"
+)
+
+// Function calling, or called from, a subsystem boundary.
+type surfaceRef struct {
+	Symbol     string   `json:"symbol"`
+	File       string   `json:"file"`
+	Subsystems []string `json:"subsystems"`
+	Calls      int      `json:"calls"`
+}
+
+// Function of the boundary of a subsystem, with its callers across it.
+type surfaceEntry struct {
+	surfaceRef
+	Callers []*surfaceRef `json:"callers"`
+	callers map[int]*surfaceRef
+}
+
+// Interface of a subsystem: its functions called by other subsystems, and
+// the functions of other subsystems it calls.
+type subsysSurface struct {
+	Subsys  string          `json:"subsys"`
+	Exports []*surfaceEntry `json:"exports"`
+	Imports []*surfaceEntry `json:"imports"`
+	exports map[int]*surfaceEntry
+	imports map[int]*surfaceEntry
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Accounts a call to the callee entry of a boundary.
+func addSurfaceCall(entries map[int]*surfaceEntry, cl call, callerSubs, calleeSubs []string) {
+	e, ok := entries[cl.calleeId]
+	if !ok {
+		e = &surfaceEntry{
+			surfaceRef: surfaceRef{Symbol: cl.callee, File: cl.calleeFile, Subsystems: calleeSubs},
+			callers:    map[int]*surfaceRef{},
+		}
+		entries[cl.calleeId] = e
+	}
+	e.Calls++
+	r, ok := e.callers[cl.callerId]
+	if !ok {
+		r = &surfaceRef{Symbol: cl.caller, File: cl.callerFile, Subsystems: callerSubs}
+		e.callers[cl.callerId] = r
+	}
+	r.Calls++
+}
+
+func sortSurfaceRefs(list []*surfaceRef) {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Symbol != list[j].Symbol {
+			return list[i].Symbol < list[j].Symbol
+		}
+		return list[i].File < list[j].File
+	})
+}
+
+// Returns the entries of a boundary sorted by name, then file.
+func surfaceEntries(entries map[int]*surfaceEntry) []*surfaceEntry {
+	res := []*surfaceEntry{}
+	for _, e := range entries {
+		e.Callers = []*surfaceRef{}
+		for _, r := range e.callers {
+			e.Callers = append(e.Callers, r)
+		}
+		sortSurfaceRefs(e.Callers)
+		res = append(res, e)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Symbol != res[j].Symbol {
+			return res[i].Symbol < res[j].Symbol
+		}
+		return res[i].File < res[j].File
+	})
+	return res
+}
+
+// Returns the interface of the subsystems of an instance, sorted by name.
+// When targets is not empty, only the listed subsystems are reported.
+func buildSurfaces(d instanceSource, instance int, targets []string) ([]*subsysSurface, error) {
+	surfaces := map[string]*subsysSurface{}
+	get := func(subsys string) *subsysSurface {
+		s, ok := surfaces[subsys]
+		if !ok {
+			s = &subsysSurface{Subsys: subsys, exports: map[int]*surfaceEntry{}, imports: map[int]*surfaceEntry{}}
+			surfaces[subsys] = s
+		}
+		return s
+	}
+
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	err = d.calls(instance, func(cl call) error {
+		callerSubs := subsystemsOf(subs, cl.callerFile)
+		calleeSubs := subsystemsOf(subs, cl.calleeFile)
+		for _, s := range calleeSubs {
+			if !contains(callerSubs, s) && (len(targets) == 0 || contains(targets, s)) {
+				addSurfaceCall(get(s).exports, cl, callerSubs, calleeSubs)
+			}
+		}
+		for _, s := range callerSubs {
+			if !contains(calleeSubs, s) && (len(targets) == 0 || contains(targets, s)) {
+				addSurfaceCall(get(s).imports, cl, callerSubs, calleeSubs)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*subsysSurface{}
+	for _, s := range surfaces {
+		s.Exports = surfaceEntries(s.exports)
+		s.Imports = surfaceEntries(s.imports)
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Subsys < res[j].Subsys })
+	return res, nil
+}
+
+func writeSurfaceText(w io.Writer, surfaces []*subsysSurface) error {
+	section := func(title string, entries []*surfaceEntry) error {
+		if _, err := fmt.Fprintf(w, "  %s:\n", title); err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if _, err := fmt.Fprintf(w, "    %s (%s) [%s] %d calls\n", e.Symbol, e.File, strings.Join(e.Subsystems, ", "), e.Calls); err != nil {
+				return err
+			}
+			for _, r := range e.Callers {
+				if _, err := fmt.Fprintf(w, "      <- %s (%s) [%s] %d\n", r.Symbol, r.File, strings.Join(r.Subsystems, ", "), r.Calls); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, s := range surfaces {
+		if _, err := fmt.Fprintf(w, "%s\n", s.Subsys); err != nil {
+			return err
+		}
+		if err := section("exports", s.Exports); err != nil {
+			return err
+		}
+		if err := section("imports", s.Imports); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Writes a row for every caller of every boundary function.
+func writeSurfaceCSV(w io.Writer, surfaces []*subsysSurface) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{"subsys", "direction", "function", "function_file", "function_subsystems",
+		"caller", "caller_file", "caller_subsystems", "calls"})
+	if err != nil {
+		return err
+	}
+	for _, s := range surfaces {
+		for _, dir := range []struct {
+			name    string
+			entries []*surfaceEntry
+		}{{"export", s.Exports}, {"import", s.Imports}} {
+			for _, e := range dir.entries {
+				for _, r := range e.Callers {
+					err := cw.Write([]string{s.Subsys, dir.name, e.Symbol, e.File, strings.Join(e.Subsystems, ";"),
+						r.Symbol, r.File, strings.Join(r.Subsystems, ";"), strconv.Itoa(r.Calls)})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeSurfaceReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	surfaces, err := buildSurfaces(d, conf.DBInstance, conf.TargetSubsys)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(surfaces)
+	case "csv":
+		return writeSurfaceCSV(w, surfaces)
+	default:
+		return writeSurfaceText(w, surfaces)
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Surface Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 3, "vfs_read", "fs/read.c", "kmalloc", "mm/slab.c", "fs/read.c:10", "0x10"},
+				{1, 3, "vfs_read", "fs/read.c", "kmalloc", "mm/slab.c", "fs/read.c:12", "0x14"},
+				{2, 3, "vfs_write", "fs/write.c", "kmalloc", "mm/slab.c", "fs/write.c:7", "0x30"},
+				{3, 4, "kmalloc", "mm/slab.c", "slab_alloc", "mm/slab.c", "mm/slab.c:40", "0x50"},
+				{1, 2, "vfs_read", "fs/read.c", "vfs_write", "fs/write.c", "fs/read.c:20", "0x18"},
+			},
+			subs: map[string][]string{"fs/read.c": {"VFS"}, "fs/write.c": {"VFS"}, "mm/slab.c": {"SLAB"}},
+		}
+	})
+
+	It("Should report the functions called across each subsystem boundary", func() {
+		surfaces, err := buildSurfaces(src, 1, nil)
+
+		Expect(err).To(BeNil())
+		Expect(surfaces).To(HaveLen(2))
+
+		slab := surfaces[0]
+		Expect(slab.Subsys).To(Equal("SLAB"))
+		Expect(slab.Imports).To(BeEmpty())
+		Expect(slab.Exports).To(HaveLen(1))
+		Expect(slab.Exports[0].Symbol).To(Equal("kmalloc"))
+		Expect(slab.Exports[0].Calls).To(Equal(3))
+		Expect(slab.Exports[0].Callers).To(Equal([]*surfaceRef{
+			{Symbol: "vfs_read", File: "fs/read.c", Subsystems: []string{"VFS"}, Calls: 2},
+			{Symbol: "vfs_write", File: "fs/write.c", Subsystems: []string{"VFS"}, Calls: 1},
+		}))
+
+		vfs := surfaces[1]
+		Expect(vfs.Subsys).To(Equal("VFS"))
+		Expect(vfs.Exports).To(BeEmpty())
+		Expect(vfs.Imports).To(HaveLen(1))
+		Expect(vfs.Imports[0].Symbol).To(Equal("kmalloc"))
+		Expect(vfs.Imports[0].Callers).To(HaveLen(2))
+	})
+
+	It("Should report only the target subsystems", func() {
+		surfaces, err := buildSurfaces(src, 1, []string{"VFS"})
+
+		Expect(err).To(BeNil())
+		Expect(surfaces).To(HaveLen(1))
+		Expect(surfaces[0].Subsys).To(Equal("VFS"))
+	})
+
+	It("Should write the text and csv formats", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "surface", ReportFormat: "text", TargetSubsys: []string{"SLAB"}}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("SLAB\n  exports:\n" +
+			"    kmalloc (mm/slab.c) [SLAB] 3 calls\n" +
+			"      <- vfs_read (fs/read.c) [VFS] 2\n" +
+			"      <- vfs_write (fs/write.c) [VFS] 1\n" +
+			"  imports:\n"))
+
+		buf.Reset()
+		conf.ReportFormat = "csv"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("subsys,direction,function,function_file,function_subsystems,caller,caller_file,caller_subsystems,calls\n" +
+			"SLAB,export,kmalloc,mm/slab.c,SLAB,vfs_read,fs/read.c,VFS,2\n" +
+			"SLAB,export,kmalloc,mm/slab.c,SLAB,vfs_write,fs/write.c,VFS,1\n"))
+	})
+})
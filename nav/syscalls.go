@@ -0,0 +1,225 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"nav/config"
+	c "nav/constants"
+)
+
+// Names of the syscall entry points of each architecture. Kernels built
+// without syscall wrappers use the generic names.
+var syscallArchPatterns = map[string][]string{
+	"x86_64":  {`^__x64_sys_`, `^__ia32_sys_`, `^__x64_compat_sys_`, `^__ia32_compat_sys_`, `^__x32_compat_sys_`},
+	"arm64":   {`^__arm64_sys_`, `^__arm64_compat_sys_`},
+	"riscv":   {`^__riscv_sys_`, `^__riscv_compat_sys_`},
+	"s390x":   {`^__s390x_sys_`, `^__s390_sys_`, `^__s390_compat_sys_`},
+	"powerpc": {`^__powerpc_sys_`, `^__powerpc_compat_sys_`},
+	"generic": {`^sys_`, `^compat_sys_`},
+}
+
+// Returns the syscall patterns of an architecture, of all of them when
+// arch is empty.
+func syscallPatterns(arch string) []string {
+	if arch != "" {
+		return syscallArchPatterns[arch]
+	}
+	var res []string
+	for _, list := range syscallArchPatterns {
+		res = append(res, list...)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// Syscall reaching a function, through the calls of path.
+type syscallPath struct {
+	Syscall string      `json:"syscall"`
+	File    string      `json:"file"`
+	Path    []cycleCall `json:"path"`
+}
+
+// Returns the syscalls reaching symbol, each with a shortest call path.
+// Functions matching excluded are not traversed, and paths are at most
+// maxDepth calls long, when not 0.
+func findSyscalls(d instanceSource, instance int, symbol string, patterns, excluded []string, maxDepth int) ([]*syscallPath, error) {
+	var res []*syscallPath
+	var targets, queue []int
+	var syscalls []*regexp.Regexp
+
+	for _, expr := range patterns {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid syscall pattern %s: %w", expr, err)
+		}
+		syscalls = append(syscalls, re)
+	}
+	isSyscall := func(name string) bool {
+		for _, re := range syscalls {
+			if re.MatchString(name) {
+				return true
+			}
+		}
+		return false
+	}
+
+	g, err := loadCallGraph(d, instance)
+	if err != nil {
+		return nil, err
+	}
+	pred := map[int][]int{}
+	for id, list := range g.succ {
+		for _, next := range list {
+			pred[next] = append(pred[next], id)
+		}
+	}
+	for _, list := range pred {
+		sort.Ints(list)
+	}
+
+	// Breadth-first walk of the callers: next is the callee leading to the
+	// target on a shortest path.
+	next := map[int]int{}
+	depth := map[int]int{}
+	for id, f := range g.functions {
+		if f.Symbol == symbol {
+			targets = append(targets, id)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("symbol %s has no calls in the instance", symbol)
+	}
+	sort.Ints(targets)
+	for _, id := range targets {
+		next[id] = id
+		queue = append(queue, id)
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if maxDepth > 0 && depth[id] >= maxDepth {
+			continue
+		}
+		for _, p := range pred[id] {
+			if _, ok := next[p]; ok || !notExcluded(g.functions[p].Symbol, excluded) {
+				continue
+			}
+			next[p] = id
+			depth[p] = depth[id] + 1
+			queue = append(queue, p)
+		}
+	}
+
+	// Call sites of the paths, the first one of each pair of functions.
+	type pair struct{ l, r int }
+	sites := map[pair]*cycleCall{}
+	for id := range next {
+		if isSyscall(g.functions[id].Symbol) && next[id] != id {
+			for p := id; next[p] != p; p = next[p] {
+				sites[pair{p, next[p]}] = nil
+			}
+		}
+	}
+	err = d.calls(instance, func(cl call) error {
+		if site, ok := sites[pair{cl.callerId, cl.calleeId}]; ok && site == nil {
+			sites[pair{cl.callerId, cl.calleeId}] = &cycleCall{cl.caller, cl.callee, cl.sourceRef, cl.addressRef}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for id := range next {
+		if !isSyscall(g.functions[id].Symbol) || next[id] == id {
+			continue
+		}
+		sp := &syscallPath{Syscall: g.functions[id].Symbol, File: g.functions[id].File}
+		for p := id; next[p] != p; p = next[p] {
+			sp.Path = append(sp.Path, *sites[pair{p, next[p]}])
+		}
+		res = append(res, sp)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Syscall != res[j].Syscall {
+			return res[i].Syscall < res[j].Syscall
+		}
+		return res[i].File < res[j].File
+	})
+	return res, nil
+}
+
+func writeSyscallsText(w io.Writer, list []*syscallPath) error {
+	for _, sp := range list {
+		names := []string{sp.Syscall}
+		for _, cl := range sp.Path {
+			names = append(names, cl.Callee)
+		}
+		if _, err := fmt.Fprintf(w, "%s (%s)\n  %s\n", sp.Syscall, sp.File, strings.Join(names, " -> ")); err != nil {
+			return err
+		}
+		for _, cl := range sp.Path {
+			if _, err := fmt.Fprintf(w, "    %s -> %s at %s %s\n", cl.Caller, cl.Callee, cl.SourceLine, cl.Address); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d syscalls\n", len(list))
+	return err
+}
+
+// Writes the union of the paths as a dot graph, highlighting the syscalls.
+func writeSyscallsDot(w io.Writer, list []*syscallPath) error {
+	seen := map[string]bool{}
+
+	if _, err := io.WriteString(w, fmtDotHeader[c.PrintAll]); err != nil {
+		return err
+	}
+	for _, sp := range list {
+		if _, err := fmt.Fprintf(w, fmtDotNode, sp.Syscall, "cyan"); err != nil {
+			return err
+		}
+		for _, cl := range sp.Path {
+			if seen[cl.Caller+"\x00"+cl.Callee] {
+				continue
+			}
+			seen[cl.Caller+"\x00"+cl.Callee] = true
+			if _, err := fmt.Fprintf(w, fmtDot[c.PrintSubsys], cl.Caller, cl.Callee); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+func writeSyscallsReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	patterns := conf.SyscallRegexps
+	if len(patterns) == 0 {
+		patterns = syscallPatterns(conf.Arch)
+	}
+	list, err := findSyscalls(d, conf.DBInstance, conf.Symbol, patterns, conf.ExcludedBefore, conf.MaxDepth)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(list)
+	case "dot":
+		return writeSyscallsDot(w, list)
+	default:
+		return writeSyscallsText(w, list)
+	}
+}
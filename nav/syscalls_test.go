@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Syscalls Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 3, "__x64_sys_read", "fs/read.c", "ksys_read", "fs/read.c", "fs/read.c:10", "0x10"},
+				{2, 4, "__x64_sys_pread64", "fs/read.c", "ksys_pread64", "fs/read.c", "fs/read.c:20", "0x20"},
+				{3, 5, "ksys_read", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:30", "0x30"},
+				{3, 5, "ksys_read", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:31", "0x31"},
+				{4, 6, "ksys_pread64", "fs/read.c", "rw_verify", "fs/read.c", "fs/read.c:40", "0x40"},
+				{6, 5, "rw_verify", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:50", "0x50"},
+				{4, 5, "ksys_pread64", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:41", "0x41"},
+				{7, 5, "__arm64_sys_read", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:60", "0x60"},
+				{8, 9, "__x64_sys_write", "fs/write.c", "vfs_write", "fs/write.c", "fs/write.c:1", "0x70"},
+			},
+		}
+	})
+
+	It("Should find the syscalls reaching a function with a shortest path", func() {
+		list, err := findSyscalls(src, 1, "vfs_read", syscallPatterns("x86_64"), nil, 0)
+
+		Expect(err).To(BeNil())
+		Expect(list).To(Equal([]*syscallPath{
+			{"__x64_sys_pread64", "fs/read.c", []cycleCall{
+				{"__x64_sys_pread64", "ksys_pread64", "fs/read.c:20", "0x20"},
+				{"ksys_pread64", "vfs_read", "fs/read.c:41", "0x41"},
+			}},
+			{"__x64_sys_read", "fs/read.c", []cycleCall{
+				{"__x64_sys_read", "ksys_read", "fs/read.c:10", "0x10"},
+				{"ksys_read", "vfs_read", "fs/read.c:30", "0x30"},
+			}},
+		}))
+	})
+
+	It("Should honor the exclusions and the max depth", func() {
+		list, err := findSyscalls(src, 1, "vfs_read", syscallPatterns(""), []string{"^ksys_read$"}, 0)
+		Expect(err).To(BeNil())
+		Expect(list).To(HaveLen(2))
+		Expect(list[0].Syscall).To(Equal("__arm64_sys_read"))
+		Expect(list[1].Syscall).To(Equal("__x64_sys_pread64"))
+
+		list, err = findSyscalls(src, 1, "vfs_read", syscallPatterns(""), nil, 1)
+		Expect(err).To(BeNil())
+		Expect(list).To(HaveLen(1))
+		Expect(list[0].Syscall).To(Equal("__arm64_sys_read"))
+	})
+
+	It("Should write the text report with the configured patterns", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "syscalls", ReportFormat: "text", Symbol: "vfs_read",
+			SyscallRegexps: []string{"^__arm64_sys_"}}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("__arm64_sys_read (fs/read.c)\n" +
+			"  __arm64_sys_read -> vfs_read\n" +
+			"    __arm64_sys_read -> vfs_read at fs/read.c:60 0x60\n" +
+			"1 syscalls\n"))
+	})
+
+	It("Should write the dot graph of the paths", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "syscalls", ReportFormat: "dot", Symbol: "vfs_read", Arch: "arm64"}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("digraph G {\nrankdir=LR; node [style=filled fillcolor=yellow]\n" +
+			"\"__arm64_sys_read\" [style=filled; fillcolor=cyan];\n" +
+			"\"__arm64_sys_read\"->\"vfs_read\"; \n" +
+			"}\n"))
+	})
+})
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"nav/config"
+	c "nav/constants"
+	"strconv"
+	"strings"
+)
+
+// Role of a function in the node list.
+const (
+	nodeEntry    = "entry"
+	nodeFunction = "function"
+)
+
+var tableEdgeHeader = []string{"caller", "callee", "caller_subsys", "callee_subsys", "source_line", "ref_addr", "depth", "call_sites"}
+var tableNodeHeader = []string{"symbol", "file", "subsystems", "type"}
+
+// Writes the edges of the graph as table rows. With annotations, the tags
+// of the caller and of the callee are in further columns.
+type tableSink struct {
+	w    *csv.Writer
+	ann  *annotations
+	info map[string]nodeInfo
+}
+
+func (s *tableSink) edge(e *graphEdge) error {
+	row := []string{
+		e.l.symbol, e.r.symbol, e.l.subsys, e.r.subsys,
+		e.r.sourceRef, e.r.addressRef, strconv.Itoa(e.depth), strconv.Itoa(e.callSites),
+	}
+	if s.ann != nil {
+		for _, symbol := range []string{e.l.symbol, e.r.symbol} {
+			info := s.info[symbol]
+			tags, _ := s.ann.tags(symbol, info.file, info.subsystems)
+			row = append(row, strings.Join(tags, ";"))
+		}
+	}
+	return s.w.Write(row)
+}
+
+func (s *tableSink) node(n graphNode) error {
+	return nil
+}
+
+// Returns a csv writer using the separator of the given output type.
+func newTableWriter(w io.Writer, outType int) *csv.Writer {
+	tw := csv.NewWriter(w)
+	if outType == c.TsvEdges || outType == c.TsvNodes {
+		tw.Comma = '\t'
+	}
+	return tw
+}
+
+// Writes the edge list or the node list of the graph as comma or tab
+// separated values. In PrintAll mode, edges are written as soon as they are
+// found.
+// Each edge row shows the first call site between the two functions, the
+// number of distinct call sites is in the last column.
+func writeTable(w io.Writer, d Datasource, conf *config.ConfValues, start int, st *navState) error {
+	if conf.Mode > c.PrintTargeted {
+		return fmt.Errorf("%s output is not available in mode %d", conf.Type, conf.Mode)
+	}
+	outType := opt2num(conf.Type)
+	tw := newTableWriter(w, outType)
+	ann, err := newAnnotations(conf)
+	if err != nil {
+		return err
+	}
+
+	if outType == c.CsvEdges || outType == c.TsvEdges {
+		sink := &tableSink{tw, ann, st.info}
+		header := tableEdgeHeader
+		if ann != nil {
+			header = append(append([]string{}, tableEdgeHeader...), "caller_tags", "callee_tags")
+		}
+		if err := tw.Write(header); err != nil {
+			return err
+		}
+		if conf.Mode == c.PrintAll {
+			st.sink = sink
+		}
+		if err := explore(d, start, conf, st); err != nil {
+			return err
+		}
+		if conf.Mode != c.PrintAll {
+			for _, e := range st.edges {
+				if err := sink.edge(e); err != nil {
+					return err
+				}
+			}
+		}
+	} else {
+		if err := explore(d, start, conf, st); err != nil {
+			return err
+		}
+		if err := writeNodeRows(tw, st, ann); err != nil {
+			return err
+		}
+	}
+	tw.Flush()
+	return tw.Error()
+}
+
+// Writes a row for each function of the graph, in the order they were met.
+// With annotations, their tags are in a further column.
+func writeNodeRows(tw *csv.Writer, st *navState, ann *annotations) error {
+	roles := map[string]string{}
+	for _, n := range st.nodes {
+		roles[n.symbol] = n.status
+	}
+	if len(st.symbols) > 0 {
+		roles[st.symbols[0]] = nodeEntry
+	}
+
+	header := tableNodeHeader
+	if ann != nil {
+		header = append(append([]string{}, tableNodeHeader...), "tags")
+	}
+	if err := tw.Write(header); err != nil {
+		return err
+	}
+	for _, symbol := range st.symbols {
+		info := st.info[symbol]
+		role, ok := roles[symbol]
+		if !ok {
+			role = nodeFunction
+		}
+		row := []string{symbol, info.file, strings.Join(info.subsystems, ";"), role}
+		if ann != nil {
+			tags, _ := ann.tags(symbol, info.file, info.subsystems)
+			row = append(row, strings.Join(tags, ";"))
+		}
+		if err := tw.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"nav/config"
+	c "nav/constants"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Table Tests", func() {
+	var d *sqlMock
+	var cfg config.Config
+
+	BeforeEach(func() {
+		// a -> b -> c, c is excluded
+		d = &sqlMock{}
+		d.init(nil)
+		d.LOADsym2numValues("a", 1, 1, nil)
+		d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", fn: "kernel/a.c", subsys: []string{"SCHED"}, symId: 1}, nil)
+		d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+			{symbol: "b", fn: "mm/b.c", subsys: []string{"MM", "SLAB"}, symId: 2, sourceRef: "kernel/a.c:3", addressRef: "0x10"},
+			{symbol: "b", fn: "mm/b.c", subsys: []string{"MM", "SLAB"}, symId: 2, sourceRef: "kernel/a.c:5", addressRef: "0x20"},
+		}, nil)
+		d.LOADgetSuccessorsByIdValues(2, 1, []entry{
+			{symbol: "c", fn: "mm/c.c", symId: 3, sourceRef: "mm/b.c:9", addressRef: "0x30"},
+		}, nil)
+		d.LOADgetSubsysFromSymbolNameValues("b", 1, "MM", nil)
+		cfg = config.Config{
+			ConfValues: config.ConfValues{
+				Symbol:        "a",
+				DBInstance:    1,
+				Mode:          c.PrintAll,
+				ExcludedAfter: []string{"^c$"},
+			},
+		}
+	})
+
+	When("writeOutput", func() {
+		It("Should write the edge list as csv", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "csvEdges"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+
+			Expect(b.String()).To(Equal("caller,callee,caller_subsys,callee_subsys,source_line,ref_addr,depth,call_sites\n" +
+				"a,b,The REST,MM,kernel/a.c:3,0x10,1,2\n" +
+				"b,c,MM,The REST,mm/b.c:9,0x30,2,1\n"))
+		})
+
+		It("Should write the node list as tsv", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "tsvNodes"
+
+			Expect(writeOutput(&b, d, &cfg)).To(Succeed())
+
+			Expect(b.String()).To(Equal("symbol\tfile\tsubsystems\ttype\n" +
+				"a\tkernel/a.c\tSCHED\tentry\n" +
+				"b\tmm/b.c\tMM;SLAB\tfunction\n" +
+				"c\tmm/c.c\t\texcluded\n"))
+		})
+
+		It("Should refuse tables in global data modes", func() {
+			cfg.ConfValues.Type = "csvEdges"
+			cfg.ConfValues.Mode = c.GDataFunc
+
+			Expect(writeOutput(&bytes.Buffer{}, d, &cfg)).ToNot(Succeed())
+		})
+	})
+})
@@ -0,0 +1,288 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"nav/config"
+)
+
+// Call site reaching a function of a feature.
+type traceSite struct {
+	Caller     string
+	SourceLine string
+	Address    string
+}
+
+func (s traceSite) String() string {
+	return fmt.Sprintf("%s at %s (%s)", s.Caller, s.SourceLine, s.Address)
+}
+
+// Function of a feature, with the call sites it is reached through.
+type traceFunction struct {
+	Symbol      string
+	File        string
+	Subsys      string
+	Maintainers []string
+	Depth       int
+	Tags        []string
+	CallSites   []traceSite
+	id          int
+}
+
+// Functions reachable from the entry symbols of a feature.
+type traceability struct {
+	Feature   string
+	Entries   []string
+	Missing   []string
+	Instance  int
+	Functions []*traceFunction
+}
+
+// Returns the subsystem of a file, as in the graphs: among its MAINTAINERS
+// entries, the one covering the most files.
+func primarySubsys(subs map[string][]string, files map[string]int, file string) string {
+	list, ok := subs[file]
+	if !ok {
+		return SUBSYS_UNDEF
+	}
+	res := list[0]
+	for _, s := range list[1:] {
+		if files[s] > files[res] {
+			res = s
+		}
+	}
+	return res
+}
+
+// Returns the functions reachable from the entries of a feature, sorted by
+// depth, name and file. Functions matching excluded are not walked
+// through, and at most maxDepth levels are walked, when not 0.
+func traceFeature(d instanceSource, instance int, entries, excluded []string, maxDepth int, a *annotations) (*traceability, error) {
+	t := &traceability{Entries: entries, Missing: []string{}, Instance: instance, Functions: []*traceFunction{}}
+
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]int{}
+	for _, list := range subs {
+		for _, s := range list {
+			files[s]++
+		}
+	}
+	g, err := loadCallGraph(d, instance)
+	if err != nil {
+		return nil, err
+	}
+	err = d.functions(instance, func(f function) error {
+		g.functions[f.id] = cycleFunction{f.symbol, f.file}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	depth := map[int]int{}
+	var queue []int
+	for _, e := range entries {
+		var found []int
+		for id, f := range g.functions {
+			if f.Symbol == e {
+				found = append(found, id)
+			}
+		}
+		if len(found) == 0 {
+			t.Missing = append(t.Missing, e)
+		}
+		sort.Ints(found)
+		for _, id := range found {
+			if _, ok := depth[id]; !ok {
+				depth[id] = 0
+				queue = append(queue, id)
+			}
+		}
+	}
+	// walked tells whether the callees of a function are part of the feature.
+	walked := func(id int) bool {
+		return (maxDepth == 0 || depth[id] < maxDepth) && (depth[id] == 0 || notExcluded(g.functions[id].Symbol, excluded))
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if !walked(id) {
+			continue
+		}
+		for _, next := range g.succ[id] {
+			if _, ok := depth[next]; !ok {
+				depth[next] = depth[id] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	byId := map[int]*traceFunction{}
+	for id, dp := range depth {
+		f := g.functions[id]
+		tags, _ := a.tags(f.Symbol, f.File, subsystemsOf(subs, f.File))
+		tf := &traceFunction{f.Symbol, f.File, primarySubsys(subs, files, f.File), subsystemsOf(subs, f.File), dp, tags, []traceSite{}, id}
+		byId[id] = tf
+		t.Functions = append(t.Functions, tf)
+	}
+	err = d.calls(instance, func(cl call) error {
+		if _, ok := depth[cl.callerId]; !ok || !walked(cl.callerId) {
+			return nil
+		}
+		if tf, ok := byId[cl.calleeId]; ok {
+			tf.CallSites = append(tf.CallSites, traceSite{cl.caller, cl.sourceRef, cl.addressRef})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, tf := range t.Functions {
+		sort.SliceStable(tf.CallSites, func(i, j int) bool {
+			if tf.CallSites[i].Caller != tf.CallSites[j].Caller {
+				return tf.CallSites[i].Caller < tf.CallSites[j].Caller
+			}
+			return tf.CallSites[i].SourceLine < tf.CallSites[j].SourceLine
+		})
+	}
+	sort.Slice(t.Functions, func(i, j int) bool {
+		a, b := t.Functions[i], t.Functions[j]
+		if a.Depth != b.Depth {
+			return a.Depth < b.Depth
+		}
+		if a.Symbol != b.Symbol {
+			return a.Symbol < b.Symbol
+		}
+		return a.File < b.File
+	})
+	return t, nil
+}
+
+var traceHeader = []string{"function", "file", "subsystem", "maintainers", "depth", "tags", "call_sites"}
+
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", "\\|", "<", "&lt;", ">", "&gt;", "*", "\\*", "_", "\\_").Replace(s)
+}
+
+func writeTraceMarkdown(w io.Writer, t *traceability) error {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# Feature traceability: %s\n\n", markdownEscape(t.Feature))
+	fmt.Fprintf(&b, "Instance: %d\n\nEntry points: %s\n\n", t.Instance, markdownEscape(strings.Join(t.Entries, ", ")))
+	if len(t.Missing) > 0 {
+		fmt.Fprintf(&b, "Entry points not found: %s\n\n", markdownEscape(strings.Join(t.Missing, ", ")))
+	}
+	fmt.Fprintf(&b, "Functions: %d\n\n", len(t.Functions))
+	b.WriteString("| Function | File | Subsystem | MAINTAINERS entries | Depth | Tags | Call sites |\n")
+	b.WriteString("|---|---|---|---|---|---|---|\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return err
+	}
+	for _, f := range t.Functions {
+		var sites []string
+		for _, s := range f.CallSites {
+			sites = append(sites, markdownEscape(s.String()))
+		}
+		_, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %d | %s | %s |\n", markdownEscape(f.Symbol), markdownEscape(f.File),
+			markdownEscape(f.Subsys), markdownEscape(strings.Join(f.Maintainers, ", ")), f.Depth,
+			markdownEscape(strings.Join(f.Tags, ", ")), strings.Join(sites, "<br>"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var traceTemplate = template.Must(template.New("traceability").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Feature traceability: {{.Feature}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Feature traceability: {{.Feature}}</h1>
+<p>Instance: {{.Instance}}</p>
+<p>Entry points: {{join .Entries ", "}}</p>
+{{if .Missing}}<p>Entry points not found: {{join .Missing ", "}}</p>
+{{end}}<p>Functions: {{len .Functions}}</p>
+<table>
+<tr><th>Function</th><th>File</th><th>Subsystem</th><th>MAINTAINERS entries</th><th>Depth</th><th>Tags</th><th>Call sites</th></tr>
+{{range .Functions}}<tr><td>{{.Symbol}}</td><td>{{.File}}</td><td>{{.Subsys}}</td><td>{{join .Maintainers ", "}}</td><td>{{.Depth}}</td><td>{{join .Tags ", "}}</td><td>{{range $i, $s := .CallSites}}{{if $i}}<br>{{end}}{{$s}}{{end}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func writeTraceCSV(w io.Writer, t *traceability) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(traceHeader); err != nil {
+		return err
+	}
+	for _, f := range t.Functions {
+		var sites []string
+		for _, s := range f.CallSites {
+			sites = append(sites, s.String())
+		}
+		err := cw.Write([]string{f.Symbol, f.File, f.Subsys, strings.Join(f.Maintainers, ";"), strconv.Itoa(f.Depth),
+			strings.Join(f.Tags, ";"), strings.Join(sites, ";")})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// Returns the entry symbols of the configured feature.
+func featureEntries(conf *config.ConfValues) []string {
+	if len(conf.FeatureSymbols) > 0 {
+		return conf.FeatureSymbols
+	}
+	return []string{conf.Symbol}
+}
+
+func writeTraceabilityReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	a, err := newAnnotations(conf)
+	if err != nil {
+		return err
+	}
+	entries := featureEntries(conf)
+	t, err := traceFeature(d, conf.DBInstance, entries, conf.ExcludedAfter, conf.MaxDepth, a)
+	if err != nil {
+		return err
+	}
+	t.Feature = conf.FeatureName
+	if t.Feature == "" {
+		t.Feature = strings.Join(entries, ", ")
+	}
+	switch conf.ReportFormat {
+	case "html":
+		return traceTemplate.Execute(w, t)
+	case "csv":
+		return writeTraceCSV(w, t)
+	default:
+		return writeTraceMarkdown(w, t)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Traceability Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "sys_open", "fs/open.c", "do_open", "fs/open.c", "fs/open.c:10", "0x10"},
+				{1, 3, "sys_open", "fs/open.c", "kmalloc", "mm/slab.c", "fs/open.c:12", "0x12"},
+				{2, 3, "do_open", "fs/open.c", "kmalloc", "mm/slab.c", "fs/open.c:30", "0x30"},
+				{3, 4, "kmalloc", "mm/slab.c", "slab_alloc", "mm/slab.c", "mm/slab.c:5", "0x50"},
+			},
+			funcs: []function{{1, "sys_open", "fs/open.c", false}, {2, "do_open", "fs/open.c", false},
+				{3, "kmalloc", "mm/slab.c", false}, {4, "slab_alloc", "mm/slab.c", false}, {5, "leaf", "lib/leaf.c", false}},
+			subs: map[string][]string{"fs/open.c": {"VFS"}, "fs/read.c": {"VFS"}, "mm/slab.c": {"MM", "SLAB"}, "mm/page.c": {"MM"}},
+		}
+	})
+
+	It("Should list the reachable functions with their call sites", func() {
+		t, err := traceFeature(src, 1, []string{"sys_open", "leaf", "gone"}, []string{"^kmalloc$"}, 0, nil)
+
+		Expect(err).To(BeNil())
+		Expect(t.Missing).To(Equal([]string{"gone"}))
+		Expect(t.Functions).To(Equal([]*traceFunction{
+			{"leaf", "lib/leaf.c", SUBSYS_UNDEF, []string{SUBSYS_UNDEF}, 0, nil, []traceSite{}, 5},
+			{"sys_open", "fs/open.c", "VFS", []string{"VFS"}, 0, nil, []traceSite{}, 1},
+			{"do_open", "fs/open.c", "VFS", []string{"VFS"}, 1, nil, []traceSite{{"sys_open", "fs/open.c:10", "0x10"}}, 2},
+			{"kmalloc", "mm/slab.c", "MM", []string{"MM", "SLAB"}, 1, nil,
+				[]traceSite{{"do_open", "fs/open.c:30", "0x30"}, {"sys_open", "fs/open.c:12", "0x12"}}, 3},
+		}))
+	})
+
+	It("Should write the markdown document", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "traceability", ReportFormat: "markdown",
+			FeatureName: "open_files", Symbol: "sys_open", MaxDepth: 1}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("# Feature traceability: open\\_files\n\n" +
+			"Instance: 1\n\nEntry points: sys\\_open\n\nFunctions: 3\n\n" +
+			"| Function | File | Subsystem | MAINTAINERS entries | Depth | Tags | Call sites |\n" +
+			"|---|---|---|---|---|---|---|\n" +
+			"| sys\\_open | fs/open.c | VFS | VFS | 0 |  |  |\n" +
+			"| do\\_open | fs/open.c | VFS | VFS | 1 |  | sys\\_open at fs/open.c:10 (0x10) |\n" +
+			"| kmalloc | mm/slab.c | MM | MM, SLAB | 1 |  | sys\\_open at fs/open.c:12 (0x12) |\n"))
+	})
+
+	It("Should write the csv and html documents", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "traceability", ReportFormat: "csv",
+			FeatureSymbols: []string{"do_open"}}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("function,file,subsystem,maintainers,depth,tags,call_sites\n" +
+			"do_open,fs/open.c,VFS,VFS,0,,\n" +
+			"kmalloc,mm/slab.c,MM,MM;SLAB,1,,do_open at fs/open.c:30 (0x30)\n" +
+			"slab_alloc,mm/slab.c,MM,MM;SLAB,2,,kmalloc at mm/slab.c:5 (0x50)\n"))
+
+		buf.Reset()
+		conf.ReportFormat = "html"
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(ContainSubstring("<h1>Feature traceability: do_open</h1>"))
+		Expect(buf.String()).To(ContainSubstring("<tr><td>kmalloc</td><td>mm/slab.c</td><td>MM</td><td>MM, SLAB</td><td>1</td><td></td><td>do_open at fs/open.c:30 (0x30)</td></tr>"))
+	})
+})
@@ -0,0 +1,172 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"context"
+	"nav/config"
+	c "nav/constants"
+	"sync"
+	"time"
+)
+
+// Reasons for a traversal to be stopped before its natural end.
+const (
+	truncNodes   = "node budget exhausted"
+	truncEdges   = "edge budget exhausted"
+	truncTimeout = "time budget exhausted"
+)
+
+// Datasource whose queries can be interrupted by a context.
+type contextBinder interface {
+	bindContext(ctx context.Context)
+}
+
+// Returns true if the configuration limits the traversal.
+func budgeted(conf *config.ConfValues) bool {
+	return conf.MaxNodes > 0 || conf.MaxEdges > 0 || conf.Timeout > 0
+}
+
+// Returns a context that expires when the configured time budget is over.
+func budgetContext(conf *config.ConfValues) (context.Context, context.CancelFunc) {
+	if conf.Timeout > 0 {
+		return context.WithTimeout(context.Background(), time.Duration(conf.Timeout)*time.Second)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// Returns the reason the traversal needs to stop, or an empty string if
+// the budget still allows exploring another node.
+func exhausted(ctx context.Context, conf *config.ConfValues, st *navState, expanded int) string {
+	if ctx.Err() != nil {
+		return truncTimeout
+	}
+	if (conf.MaxNodes > 0) && (expanded >= conf.MaxNodes) {
+		return truncNodes
+	}
+	if (conf.MaxEdges > 0) && (st.archnum >= conf.MaxEdges) {
+		return truncEdges
+	}
+	return ""
+}
+
+// Fetches concurrently, using conf.Workers goroutines, the data needed to
+// expand the given nodes, so that the subsequent expansion is served by the
+// datasource cache. Results are not returned: errors will be hit again, and
+// handled, during the expansion.
+func prefetch(ctx context.Context, d Datasource, items []navItem, conf *config.ConfValues) {
+	var wg sync.WaitGroup
+
+	jobs := make(chan int)
+	for i := 0; i < conf.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for symbolId := range jobs {
+				successors, err := d.getSuccessorsById(symbolId, conf.DBInstance)
+				if err != nil {
+					continue
+				}
+				for _, s := range successors {
+					d.getSubsysFromSymbolName(s.symbol, conf.DBInstance)
+				}
+			}
+		}()
+	}
+	for _, item := range items {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- item.symId
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// Computes the call tree of a given function breadth-first, so that the
+// functions nearest to the start are explored first.
+// The exploration stops when the context is done or the node/edge budget is
+// exhausted; in such case the partial result is marked as truncated.
+// Budgets are checked before each node expansion, therefore the edge budget
+// can be exceeded by the successors of the last expanded node. When the
+// datasource supports it, its queries are bound to the context, so that a
+// slow query does not outlive the time budget.
+// If more than one worker is configured, the data of each BFS level is
+// fetched concurrently before the level is expanded, in order, from the
+// cache: the result is the same as the sequential exploration.
+func navigateBFS(ctx context.Context, d Datasource, symbolId int, start node, conf *config.ConfValues, st *navState) {
+	if b, ok := d.(contextBinder); ok {
+		b.bindContext(ctx)
+		defer b.bindContext(nil)
+	}
+	queue := []navItem{{symbolId, start, 0}}
+	st.visit(symbolId)
+
+	expanded := 0
+	for len(queue) > 0 {
+		frontier := queue
+		queue = nil
+		if conf.Workers > 1 {
+			batch := frontier
+			if (conf.MaxNodes > 0) && (len(batch) > conf.MaxNodes-expanded) {
+				batch = batch[:conf.MaxNodes-expanded]
+			}
+			prefetch(ctx, d, batch, conf)
+		}
+		for _, item := range frontier {
+			if reason := exhausted(ctx, conf, st, expanded); reason != "" {
+				st.truncated = reason
+				return
+			}
+			prevErr := st.err
+			expand(d, item.symId, item.parent, item.depth, conf, st, func(next navItem) {
+				st.visit(next.symId)
+				queue = append(queue, next)
+			})
+			if ctx.Err() != nil {
+				// a new error, if any, comes from the interrupted query.
+				st.err = prevErr
+				st.truncated = truncTimeout
+				return
+			}
+			expanded++
+		}
+	}
+}
+
+// Explores the call tree of the function start, as requested by conf.
+func explore(d Datasource, start int, conf *config.ConfValues, st *navState) error {
+	entry, err := d.getEntryById(start, conf.DBInstance)
+	if err != nil {
+		return err
+	}
+	startSubsys, err := d.getSubsysFromSymbolName(entry.symbol, conf.DBInstance)
+	if err != nil {
+		return err
+	}
+	if startSubsys == "" {
+		startSubsys = SUBSYS_UNDEF
+	}
+
+	if (conf.Mode == c.PrintTargeted) && len(conf.TargetSubsys) == 0 {
+		targSubsysTmp, err := d.getSubsysFromSymbolName(conf.Symbol, conf.DBInstance)
+		if err != nil {
+			return err
+		}
+		conf.TargetSubsys = append(conf.TargetSubsys, targSubsysTmp)
+	}
+
+	startNode := node{startSubsys, entry.symbol, "entry point", "0x0"}
+	st.addInfo(entry.symbol, startSubsys, entry)
+	if budgeted(conf) || (conf.Workers > 1) {
+		ctx, cancel := budgetContext(conf)
+		defer cancel()
+		navigateBFS(ctx, d, start, startNode, conf, st)
+	} else {
+		navigate(d, start, startNode, 0, conf, st)
+	}
+	return st.err
+}
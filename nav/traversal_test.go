@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"nav/config"
+	c "nav/constants"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Traversal Tests", func() {
+	var d *sqlMock
+	var conf config.ConfValues
+	var start node
+
+	BeforeEach(func() {
+		// a -> b -> d
+		// a -> c
+		d = &sqlMock{}
+		d.init(nil)
+		d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+			{symbol: "b", symId: 2, addressRef: "0x10"},
+			{symbol: "c", symId: 3, addressRef: "0x20"},
+		}, nil)
+		d.LOADgetSuccessorsByIdValues(2, 1, []entry{
+			{symbol: "d", symId: 4, addressRef: "0x30"},
+		}, nil)
+		conf = config.ConfValues{DBInstance: 1, Mode: c.PrintAll}
+		start = node{"S0", "a", "entry point", "0x0"}
+	})
+
+	When("budgeted", func() {
+		It("Should return false if no limits are set", func() {
+			Expect(budgeted(&conf)).To(BeFalse())
+		})
+
+		It("Should return true if any limit is set", func() {
+			conf.MaxEdges = 1
+			Expect(budgeted(&conf)).To(BeTrue())
+		})
+	})
+
+	When("navigateBFS", func() {
+		It("Should explore the nearest functions first", func() {
+			st := newNavState()
+
+			navigateBFS(context.Background(), d, 1, start, &conf, st)
+
+			Expect(st.truncated).To(BeEmpty())
+			Expect(st.order).To(Equal([]int{1, 2, 3, 4}))
+			Expect(st.edges).To(HaveLen(3))
+			Expect(st.edges[1].r.symbol).To(Equal("c"))
+			Expect(st.edges[2].r.symbol).To(Equal("d"))
+		})
+
+		It("Should stop when the node budget is exhausted", func() {
+			conf.MaxNodes = 1
+			st := newNavState()
+
+			navigateBFS(context.Background(), d, 1, start, &conf, st)
+
+			Expect(st.truncated).To(Equal(truncNodes))
+			Expect(st.edges).To(HaveLen(2))
+		})
+
+		It("Should stop when the edge budget is exhausted", func() {
+			conf.MaxEdges = 2
+			st := newNavState()
+
+			navigateBFS(context.Background(), d, 1, start, &conf, st)
+
+			Expect(st.truncated).To(Equal(truncEdges))
+			Expect(st.edges).To(HaveLen(2))
+		})
+
+		It("Should produce the same result with concurrent workers", func() {
+			seq := newNavState()
+			navigateBFS(context.Background(), d, 1, start, &conf, seq)
+
+			conf.Workers = 4
+			par := newNavState()
+			navigateBFS(context.Background(), d, 1, start, &conf, par)
+
+			Expect(par.order).To(Equal(seq.order))
+			Expect(par.edges).To(Equal(seq.edges))
+		})
+
+		It("Should stop when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			st := newNavState()
+
+			navigateBFS(ctx, d, 1, start, &conf, st)
+
+			Expect(st.truncated).To(Equal(truncTimeout))
+			Expect(st.edges).To(BeEmpty())
+		})
+
+		It("Should interrupt a query blocked past the deadline", func() {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			mock.ExpectQuery("select caller, callee, source_line, ref_addr from xrefs where caller = 1 and xref_instance_id_ref = 1").
+				WillDelayFor(10 * time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"caller", "callee", "source_line", "ref_addr"}))
+			sdb := &SqlDB{db: db, cache: Cache{successors: map[int][]entry{}, entries: map[int]entry{}, subSys: map[string]string{}}}
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			st := newNavState()
+
+			begin := time.Now()
+			navigateBFS(ctx, sdb, 1, start, &conf, st)
+
+			Expect(time.Since(begin)).To(BeNumerically("<", 5*time.Second))
+			Expect(st.truncated).To(Equal(truncTimeout))
+			Expect(st.err).To(BeNil())
+			Expect(sdb.ctx).To(BeNil())
+		})
+
+		It("Should interrupt a query of a reverse call tree blocked past the timeout", func() {
+			db, mock, _ := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			defer db.Close()
+			mock.ExpectQuery("select caller, callee, source_line, ref_addr from xrefs where callee = 1 and xref_instance_id_ref = 1").
+				WillDelayFor(10 * time.Second).
+				WillReturnRows(sqlmock.NewRows([]string{"caller", "callee", "source_line", "ref_addr"}))
+			sdb := &SqlDB{db: db, cache: Cache{predecessors: map[int][]entry{}, entries: map[int]entry{1: {symbol: "a", fn: "a.c", symId: 1}},
+				subSys: map[string]string{"a": "SCHED"}}}
+			var b bytes.Buffer
+			tree := config.ConfValues{Symbol: "a", DBInstance: 1, Mode: c.PrintAll, Type: "cflow", Reverse: true, Timeout: 1}
+
+			begin := time.Now()
+			Expect(writeCflow(&b, sdb, &tree, 1, newNavState())).To(Succeed())
+
+			Expect(time.Since(begin)).To(BeNumerically("<", 5*time.Second))
+			Expect(b.String()).To(Equal("a() <a.c>\n(partial tree: " + truncTimeout + ")\n"))
+			Expect(sdb.ctx).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,203 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"nav/config"
+)
+
+// Names of the helpers the syscall wrappers are made of, besides the entry
+// points of syscallArchPatterns; none of them is called directly.
+var syscallHelperPatterns = []string{`^__se_sys_`, `^__do_sys_`, `^__se_compat_sys_`, `^__do_compat_sys_`, `^__x32_sys_`}
+
+const (
+	ksymtabPrefix  = "__ksymtab_"
+	initcallPrefix = "__initcall_"
+)
+
+// Level suffixes of the initcall symbols, i.e. __initcall_foo6 or
+// __initcall__kmod_bar__12_345_foo6s.
+var initcallLevel = regexp.MustCompile(`(early|rootfs|[0-9]s?)$`)
+
+// Functions reached by the kernel through tables filled at build time.
+type entryPoints struct {
+	patterns  []*regexp.Regexp
+	exported  map[string]bool
+	initcalls []string
+}
+
+func newEntryPoints(d instanceSource, instance int, extra []string) (*entryPoints, error) {
+	ep := &entryPoints{exported: map[string]bool{}}
+
+	patterns := append(syscallPatterns(""), syscallHelperPatterns...)
+	for _, expr := range append(patterns, extra...) {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry point pattern %s: %w", expr, err)
+		}
+		ep.patterns = append(ep.patterns, re)
+	}
+	markers, err := d.markerSymbols(instance)
+	if err != nil && !skipMissingTable(err, "detection of the exported functions and initcalls") {
+		return nil, err
+	}
+	for _, m := range markers {
+		switch {
+		case strings.HasPrefix(m, ksymtabPrefix):
+			ep.exported[strings.TrimPrefix(m, ksymtabPrefix)] = true
+		case strings.HasPrefix(m, initcallPrefix):
+			ep.initcalls = append(ep.initcalls, initcallLevel.ReplaceAllString(m, ""))
+		}
+	}
+	return ep, nil
+}
+
+func (ep *entryPoints) match(symbol string) bool {
+	if ep.exported[symbol] {
+		return true
+	}
+	for _, re := range ep.patterns {
+		if re.MatchString(symbol) {
+			return true
+		}
+	}
+	for _, ic := range ep.initcalls {
+		if strings.HasSuffix(ic, "_"+symbol) {
+			return true
+		}
+	}
+	return false
+}
+
+type unreferencedFile struct {
+	File      string   `json:"file"`
+	Functions []string `json:"functions"`
+}
+
+type unreferencedSubsys struct {
+	Subsys string              `json:"subsys"`
+	Files  []*unreferencedFile `json:"files"`
+}
+
+// Returns the functions of an instance no other function calls, and whose
+// address is not taken, which are not entry points, grouped by subsystem
+// and file, all sorted by name.
+func findUnreferenced(d instanceSource, instance int, extra []string) ([]*unreferencedSubsys, error) {
+	called := map[int]bool{}
+	groups := map[string]map[string][]string{}
+
+	ep, err := newEntryPoints(d, instance, extra)
+	if err != nil {
+		return nil, err
+	}
+	data, err := d.dataReferenced(instance)
+	if err != nil && !skipMissingTable(err, "detection of the functions whose address is taken") {
+		return nil, err
+	}
+	subs, err := d.fileSubsystems(instance)
+	if err != nil {
+		return nil, err
+	}
+	err = d.calls(instance, func(cl call) error {
+		if cl.callerId != cl.calleeId {
+			called[cl.calleeId] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = d.functions(instance, func(f function) error {
+		if called[f.id] || f.indirect || data[f.symbol] || ep.match(f.symbol) {
+			return nil
+		}
+		for _, s := range subsystemsOf(subs, f.file) {
+			if groups[s] == nil {
+				groups[s] = map[string][]string{}
+			}
+			groups[s][f.file] = append(groups[s][f.file], f.symbol)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := []*unreferencedSubsys{}
+	for s, files := range groups {
+		group := &unreferencedSubsys{Subsys: s}
+		for file, list := range files {
+			sort.Strings(list)
+			group.Files = append(group.Files, &unreferencedFile{file, list})
+		}
+		sort.Slice(group.Files, func(i, j int) bool { return group.Files[i].File < group.Files[j].File })
+		res = append(res, group)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Subsys < res[j].Subsys })
+	return res, nil
+}
+
+func writeUnreferencedText(w io.Writer, groups []*unreferencedSubsys) error {
+	for _, g := range groups {
+		if _, err := fmt.Fprintf(w, "%s\n", g.Subsys); err != nil {
+			return err
+		}
+		for _, f := range g.Files {
+			if _, err := fmt.Fprintf(w, "  %s\n", f.File); err != nil {
+				return err
+			}
+			for _, fn := range f.Functions {
+				if _, err := fmt.Fprintf(w, "    %s\n", fn); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func writeUnreferencedCSV(w io.Writer, groups []*unreferencedSubsys) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"subsys", "file", "function"}); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		for _, f := range g.Files {
+			for _, fn := range f.Functions {
+				if err := cw.Write([]string{g.Subsys, f.File, fn}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeUnreferencedReport(w io.Writer, d instanceSource, conf *config.ConfValues) error {
+	groups, err := findUnreferenced(d, conf.DBInstance, conf.EntryPoints)
+	if err != nil {
+		return err
+	}
+	switch conf.ReportFormat {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(groups)
+	case "csv":
+		return writeUnreferencedCSV(w, groups)
+	default:
+		return writeUnreferencedText(w, groups)
+	}
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"nav/config"
+)
+
+var _ = Describe("Unreferenced Tests", func() {
+	var src *fakeInstance
+
+	BeforeEach(func() {
+		src = &fakeInstance{
+			list: []call{
+				{1, 2, "__x64_sys_read", "fs/read.c", "vfs_read", "fs/read.c", "fs/read.c:1", "0x1"},
+				{3, 3, "walk", "lib/walk.c", "walk", "lib/walk.c", "lib/walk.c:4", "0x4"},
+			},
+			funcs: []function{
+				{1, "__x64_sys_read", "fs/read.c", false},
+				{2, "vfs_read", "fs/read.c", false},
+				{3, "walk", "lib/walk.c", false},
+				{4, "old_helper", "fs/read.c", false},
+				{5, "ops_read", "fs/ops.c", false},
+				{6, "handler", "fs/ops.c", true},
+				{7, "kmalloc", "mm/slab.c", false},
+				{8, "foo_init", "drivers/foo.c", false},
+				{9, "bar_init", "drivers/bar.c", false},
+				{10, "debug_dump", "lib/debug.c", false},
+			},
+			subs:    map[string][]string{"fs/read.c": {"VFS"}, "fs/ops.c": {"VFS"}},
+			data:    map[string]bool{"ops_read": true},
+			markers: []string{"__initcall__kmod_foo__12_345_foo_init6", "__initcall_bar_initearly", "__ksymtab_kmalloc"},
+		}
+	})
+
+	It("Should skip called, address taken and entry point functions", func() {
+		groups, err := findUnreferenced(src, 1, []string{"^debug_"})
+
+		Expect(err).To(BeNil())
+		Expect(groups).To(Equal([]*unreferencedSubsys{
+			{SUBSYS_UNDEF, []*unreferencedFile{{"lib/walk.c", []string{"walk"}}}},
+			{"VFS", []*unreferencedFile{{"fs/read.c", []string{"old_helper"}}}},
+		}))
+	})
+
+	It("Should skip the syscall entry points and wrapper helpers", func() {
+		for i, name := range []string{"__arm64_compat_sys_read", "__s390_sys_read", "compat_sys_read", "__se_sys_read", "__do_sys_read", "__x32_sys_read"} {
+			src.funcs = append(src.funcs, function{20 + i, name, "fs/read.c", false})
+		}
+
+		groups, err := findUnreferenced(src, 1, []string{"^debug_"})
+
+		Expect(err).To(BeNil())
+		Expect(groups).To(Equal([]*unreferencedSubsys{
+			{SUBSYS_UNDEF, []*unreferencedFile{{"lib/walk.c", []string{"walk"}}}},
+			{"VFS", []*unreferencedFile{{"fs/read.c", []string{"old_helper"}}}},
+		}))
+	})
+
+	It("Should skip the checks relying on tables the database misses", func() {
+		src.missing = fmt.Errorf("nm_symbol: %w", errMissingTable)
+
+		groups, err := findUnreferenced(src, 1, nil)
+
+		Expect(err).To(BeNil())
+		Expect(groups).To(Equal([]*unreferencedSubsys{
+			{SUBSYS_UNDEF, []*unreferencedFile{
+				{"drivers/bar.c", []string{"bar_init"}},
+				{"drivers/foo.c", []string{"foo_init"}},
+				{"lib/debug.c", []string{"debug_dump"}},
+				{"lib/walk.c", []string{"walk"}},
+				{"mm/slab.c", []string{"kmalloc"}},
+			}},
+			{"VFS", []*unreferencedFile{{"fs/ops.c", []string{"ops_read"}}, {"fs/read.c", []string{"old_helper"}}}},
+		}))
+
+		src.missing = errors.New("connection lost")
+		_, err = findUnreferenced(src, 1, nil)
+		Expect(err).To(MatchError("connection lost"))
+	})
+
+	It("Should write the csv format", func() {
+		var buf bytes.Buffer
+		conf := config.ConfValues{DBInstance: 1, Report: "unreferenced", ReportFormat: "csv"}
+
+		Expect(writeReport(&buf, src, &conf)).To(Succeed())
+		Expect(buf.String()).To(Equal("subsys,file,function\n" +
+			"The REST,lib/debug.c,debug_dump\n" +
+			"The REST,lib/walk.c,walk\n" +
+			"VFS,fs/read.c,old_helper\n"))
+	})
+
+	It("Should reject an invalid entry point pattern", func() {
+		_, err := findUnreferenced(src, 1, []string{"("})
+
+		Expect(err).ToNot(BeNil())
+		Expect(err.Error()).To(HavePrefix("invalid entry point pattern ("))
+	})
+})
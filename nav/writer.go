@@ -0,0 +1,107 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"io"
+	c "nav/constants"
+)
+
+// Receives the graph elements as soon as they are final, so that they can be
+// written out without holding the whole output in memory.
+type graphSink interface {
+	edge(e *graphEdge) error
+	node(n graphNode) error
+}
+
+// Writes the graph elements as dot statements.
+type dotSink struct {
+	w        io.Writer
+	mode     c.OutMode
+	weighted bool
+	links    *linker
+}
+
+func (s *dotSink) edge(e *graphEdge) error {
+	_, err := io.WriteString(s.w, dotEdges([]*graphEdge{e}, s.mode, s.weighted, s.links))
+	return err
+}
+
+func (s *dotSink) node(n graphNode) error {
+	_, err := io.WriteString(s.w, dotNode(n))
+	return err
+}
+
+// Edge record in the json lines output.
+type jsonLineEdge struct {
+	Type string `json:"type"`
+	jsonEdge
+	Depth int `json:"depth"`
+}
+
+// Node record in the json lines output.
+type jsonLineNode struct {
+	Type   string `json:"type"`
+	Symbol string `json:"symbol"`
+	Status string `json:"status"`
+}
+
+// Annotation record in the json lines output.
+type jsonLineAnnotation struct {
+	Type   string   `json:"type"`
+	Symbol string   `json:"symbol"`
+	Tags   []string `json:"tags"`
+	Color  string   `json:"color,omitempty"`
+}
+
+// Last record in the json lines output.
+type jsonLineSummary struct {
+	Type      string `json:"type"`
+	GraphType string `json:"graph_type"`
+	Edges     int    `json:"edges"`
+	Symbols   int    `json:"symbols"`
+	Truncated bool   `json:"truncated"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Writes the graph elements as json objects, one per line.
+type jsonLinesSink struct {
+	enc  *json.Encoder
+	mode c.OutMode
+	src  *snippetReader
+}
+
+func newJsonLinesSink(w io.Writer, mode c.OutMode, src *snippetReader) *jsonLinesSink {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &jsonLinesSink{enc, mode, src}
+}
+
+func (s *jsonLinesSink) edge(e *graphEdge) error {
+	return s.enc.Encode(jsonLineEdge{"edge", toJsonEdge(e, s.mode, s.src), e.depth})
+}
+
+func (s *jsonLinesSink) node(n graphNode) error {
+	return s.enc.Encode(jsonLineNode{"node", n.symbol, n.status})
+}
+
+// Writes the annotations of the functions, in the order they were met.
+func (s *jsonLinesSink) annotations(st *navState, a *annotations) error {
+	for _, symbol := range st.symbols {
+		info := st.info[symbol]
+		if tags, color := a.tags(symbol, info.file, info.subsystems); len(tags) > 0 {
+			if err := s.enc.Encode(jsonLineAnnotation{"annotation", symbol, tags, color}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonLinesSink) summary(graphType string, st *navState) error {
+	return s.enc.Encode(jsonLineSummary{"summary", graphType, st.archnum, len(st.visited), st.truncated != "", st.truncated})
+}
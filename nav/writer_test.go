@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2022 Red Hat, Inc.
+ * SPDX-License-Identifier: GPL-2.0-or-later
+ */
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"nav/config"
+	c "nav/constants"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Writer Tests", func() {
+	var d *sqlMock
+	var cfg config.Config
+
+	BeforeEach(func() {
+		// a -> b -> c, c is excluded
+		d = &sqlMock{}
+		d.init(nil)
+		d.LOADsym2numValues("a", 1, 1, nil)
+		d.LOADgetEntryByIdValues(1, 1, entry{symbol: "a", symId: 1}, nil)
+		d.LOADgetSuccessorsByIdValues(1, 1, []entry{
+			{symbol: "b", symId: 2, addressRef: "0x10"},
+			{symbol: "b", symId: 2, addressRef: "0x20"},
+		}, nil)
+		d.LOADgetSuccessorsByIdValues(2, 1, []entry{
+			{symbol: "c", symId: 3, addressRef: "0x30"},
+		}, nil)
+		d.LOADsymbSubsysValues([]int{1, 2}, 1, "{\"FuncName\":\"a\", \"subsystems\":[]},{\"FuncName\":\"b\", \"subsystems\":[]}", nil)
+		cfg = config.Config{
+			ConfValues: config.ConfValues{
+				Symbol:        "a",
+				DBInstance:    1,
+				Mode:          c.PrintAll,
+				ExcludedAfter: []string{"^c$"},
+				Type:          "graphOnly",
+			},
+		}
+	})
+
+	When("dotSink", func() {
+		It("Should write edges and nodes as dot statements", func() {
+			var b bytes.Buffer
+			s := &dotSink{&b, c.PrintAll, false, nil}
+
+			Expect(s.edge(&graphEdge{l: node{symbol: "a"}, r: node{symbol: "b"}, id: 7})).To(BeNil())
+			Expect(s.node(graphNode{"b", nodeNotExplored})).To(BeNil())
+
+			Expect(b.String()).To(Equal("\"a\"->\"b\" [ edgeid = \"7\"]; \n\"b\" [style=filled; fillcolor=red];\n"))
+		})
+	})
+
+	When("writeOutput", func() {
+		It("Should write one json object per line", func() {
+			var b bytes.Buffer
+			cfg.ConfValues.Type = "jsonLines"
+
+			Expect(writeOutput(&b, d, &cfg)).To(BeNil())
+
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			Expect(lines).To(Equal([]string{
+				`{"type":"edge","caller":"a","callee":"b","call_sites":2,"symbol_pairs":1,"depth":1}`,
+				`{"type":"edge","caller":"b","callee":"c","call_sites":1,"symbol_pairs":1,"depth":2}`,
+				`{"type":"node","symbol":"c","status":"excluded"}`,
+				`{"type":"summary","graph_type":"jsonLines","edges":2,"symbols":2,"truncated":false}`,
+			}))
+		})
+
+		It("Should not retain the streamed edges", func() {
+			cfg.ConfValues.Type = "jsonLines"
+			st := newNavState()
+
+			Expect(writeJsonLines(io.Discard, d, &cfg.ConfValues, 1, st, nil)).To(BeNil())
+
+			Expect(st.edges).To(BeEmpty())
+			Expect(st.prod).To(BeEmpty())
+			Expect(st.order).To(BeEmpty())
+			Expect(st.sent).To(Equal(map[string]bool{"a->b": true, "b->c": true}))
+			Expect(st.visited).To(Equal(map[int]bool{1: true, 2: true}))
+		})
+
+		It("Should refuse json lines in global data modes", func() {
+			cfg.ConfValues.Type = "jsonLines"
+			cfg.ConfValues.Mode = c.GDataFunc
+
+			Expect(writeOutput(io.Discard, d, &cfg)).ToNot(BeNil())
+		})
+
+		It("Should escape the graph of the plain json output", func() {
+			var b bytes.Buffer
+			var res struct {
+				Graph     string            `json:"graph"`
+				Edges     []json.RawMessage `json:"edges"`
+				Truncated bool              `json:"truncated"`
+			}
+			cfg.ConfValues.Type = "jsonOutputPlain"
+
+			Expect(writeOutput(&b, d, &cfg)).To(BeNil())
+			Expect(json.Unmarshal(b.Bytes(), &res)).To(BeNil())
+
+			plain, err := generateOutput(d, &config.Config{ConfValues: config.ConfValues{
+				Symbol: "a", DBInstance: 1, Mode: c.PrintAll, ExcludedAfter: []string{"^c$"}, Type: "graphOnly",
+			}})
+			Expect(err).To(BeNil())
+			Expect(res.Graph).To(Equal(plain))
+			Expect(res.Edges).To(HaveLen(2))
+			Expect(res.Truncated).To(BeFalse())
+		})
+
+		It("Should stream the compressed graph into the json output", func() {
+			var b bytes.Buffer
+			var res struct {
+				Graph string `json:"graph"`
+			}
+			cfg.ConfValues.Type = "jsonOutputGZB64"
+
+			Expect(writeOutput(&b, d, &cfg)).To(BeNil())
+			Expect(json.Unmarshal(b.Bytes(), &res)).To(BeNil())
+
+			gz, err := base64.StdEncoding.DecodeString(res.Graph)
+			Expect(err).To(BeNil())
+			r, err := gzip.NewReader(bytes.NewReader(gz))
+			Expect(err).To(BeNil())
+			dot, err := io.ReadAll(r)
+			Expect(err).To(BeNil())
+
+			plain, err := generateOutput(d, &config.Config{ConfValues: config.ConfValues{
+				Symbol: "a", DBInstance: 1, Mode: c.PrintAll, ExcludedAfter: []string{"^c$"}, Type: "graphOnly",
+			}})
+			Expect(err).To(BeNil())
+			Expect(string(dot)).To(Equal(plain))
+		})
+	})
+})